@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/formancehq/go-libs/v2/bun/bunconnect"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/formancehq/webhooks/pkg/storage/postgres"
+	"github.com/spf13/cobra"
+)
+
+func newConfigsCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "configs",
+		Short: "Manage webhook configs directly against the database",
+	}
+	command.AddCommand(
+		newConfigsApplyCommand(),
+		newConfigsExportCommand(),
+		newConfigsImportCommand(),
+	)
+	return command
+}
+
+// openConfigsStore opens the store used by the offline configs commands. The
+// returned function closes the underlying database connection.
+func openConfigsStore(cmd *cobra.Command) (storage.Store, func(), error) {
+	options, err := bunconnect.ConnectionOptionsFromFlags(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := bunconnect.OpenSQLDB(cmd.Context(), *options)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := postgres.NewStore(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	return store, func() { _ = db.Close() }, nil
+}
@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/formancehq/go-libs/v2/bun/bunconnect"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configsApplyFileFlag   = "file"
+	configsApplyDryRunFlag = "dry-run"
+	configsApplyDeleteFlag = "delete-mode"
+)
+
+// configsManifest is the desired state read by `configs apply`. JSON manifests
+// are accepted as well since JSON is valid YAML.
+type configsManifest struct {
+	Configs []manifestConfig `yaml:"configs"`
+}
+
+type manifestConfig struct {
+	Name       string           `yaml:"name"`
+	Endpoint   string           `yaml:"endpoint"`
+	EventTypes []string         `yaml:"eventTypes"`
+	Secret     *secretReference `yaml:"secret"`
+}
+
+// secretReference points to a signing secret stored outside the manifest, so
+// that the manifest itself can be committed to Git.
+type secretReference struct {
+	FromEnv  string `yaml:"fromEnv"`
+	FromFile string `yaml:"fromFile"`
+}
+
+func (r *secretReference) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return errors.New("secrets must not be inlined: use fromEnv or fromFile")
+	}
+	type plain secretReference
+	return node.Decode((*plain)(r))
+}
+
+type desiredConfig struct {
+	webhooks.ConfigUser
+	// manageSecret is false when the manifest does not reference a secret: the
+	// current secret is then left untouched on update.
+	manageSecret bool
+}
+
+type configUpdate struct {
+	id      string
+	config  webhooks.ConfigUser
+	changes []string
+}
+
+type configsPlan struct {
+	creates []webhooks.ConfigUser
+	updates []configUpdate
+	deletes []webhooks.Config
+}
+
+func (p configsPlan) empty() bool {
+	return len(p.creates) == 0 && len(p.updates) == 0 && len(p.deletes) == 0
+}
+
+func newConfigsApplyCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "apply",
+		Short: "Reconcile named webhook configs with a YAML or JSON desired state",
+		Long: "Reconcile named webhook configs with a YAML or JSON desired state.\n\n" +
+			"Configs are matched by name. Named configs missing from the file are deleted; " +
+			"configs without a name are not managed by this command.",
+		Args: cobra.NoArgs,
+		RunE: runConfigsApply,
+	}
+	bunconnect.AddFlags(command.Flags())
+	command.Flags().StringP(configsApplyFileFlag, "f", "", "path to the desired state file")
+	command.Flags().Bool(configsApplyDryRunFlag, false, "print the planned changes without applying them")
+	command.Flags().String(configsApplyDeleteFlag, webhooks.DeleteModeCancel, "deletion mode of removed configs: cancel, drain or purge")
+	_ = command.MarkFlagRequired(configsApplyFileFlag)
+	return command
+}
+
+func runConfigsApply(cmd *cobra.Command, _ []string) error {
+	path, _ := cmd.Flags().GetString(configsApplyFileFlag)
+	dryRun, _ := cmd.Flags().GetBool(configsApplyDryRunFlag)
+	deleteMode, _ := cmd.Flags().GetString(configsApplyDeleteFlag)
+	if !webhooks.IsValidDeleteMode(deleteMode) {
+		return fmt.Errorf("invalid --%s %q", configsApplyDeleteFlag, deleteMode)
+	}
+
+	manifest, err := readConfigsManifest(path)
+	if err != nil {
+		return err
+	}
+	desired, err := resolveConfigsManifest(manifest, filepath.Dir(path), os.LookupEnv, os.ReadFile)
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openConfigsStore(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	current, err := store.FindManyConfigs(cmd.Context(), map[string]any{})
+	if err != nil {
+		return err
+	}
+	plan, err := planConfigs(current, desired)
+	if err != nil {
+		return err
+	}
+	printConfigsPlan(cmd.OutOrStdout(), plan)
+	if dryRun || plan.empty() {
+		return nil
+	}
+
+	for _, cfg := range plan.creates {
+		if _, err := store.InsertOneConfig(cmd.Context(), cfg); err != nil {
+			return errors.Wrapf(err, "creating config %s", cfg.Name)
+		}
+	}
+	for _, update := range plan.updates {
+		if err := store.UpdateOneConfig(cmd.Context(), update.id, update.config); err != nil {
+			return errors.Wrapf(err, "updating config %s", update.config.Name)
+		}
+	}
+	for _, cfg := range plan.deletes {
+		if err := store.DeleteOneConfig(cmd.Context(), cfg.ID, deleteMode); err != nil {
+			return errors.Wrapf(err, "deleting config %s", cfg.Name)
+		}
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Apply complete.")
+	return nil
+}
+
+func readConfigsManifest(path string) (configsManifest, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return configsManifest{}, errors.Wrap(err, "reading desired state")
+	}
+	manifest := configsManifest{}
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	// An empty or truncated file must not read as "no configs": applying it
+	// would delete every named config.
+	if err := decoder.Decode(&manifest); errors.Is(err, io.EOF) {
+		return configsManifest{}, errors.New("desired state is empty")
+	} else if err != nil {
+		return configsManifest{}, errors.Wrap(err, "decoding desired state")
+	}
+	if manifest.Configs == nil {
+		return configsManifest{}, errors.New("desired state has no configs list: use `configs: []` to delete every named config")
+	}
+	return manifest, nil
+}
+
+func resolveConfigsManifest(
+	manifest configsManifest,
+	baseDir string,
+	lookupEnv func(string) (string, bool),
+	readFile func(string) ([]byte, error),
+) ([]desiredConfig, error) {
+	seen := map[string]struct{}{}
+	desired := make([]desiredConfig, 0, len(manifest.Configs))
+	for index, entry := range manifest.Configs {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("configs[%d]: name is required", index)
+		}
+		if _, ok := seen[entry.Name]; ok {
+			return nil, fmt.Errorf("configs[%d]: duplicate name %q", index, entry.Name)
+		}
+		seen[entry.Name] = struct{}{}
+
+		cfg := desiredConfig{ConfigUser: webhooks.ConfigUser{
+			Name:       entry.Name,
+			Endpoint:   entry.Endpoint,
+			EventTypes: slices.Clone(entry.EventTypes),
+		}}
+		if entry.Secret != nil {
+			secret, err := resolveSecretReference(*entry.Secret, baseDir, lookupEnv, readFile)
+			if err != nil {
+				return nil, fmt.Errorf("config %q: %w", entry.Name, err)
+			}
+			cfg.Secret = secret
+			cfg.manageSecret = true
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("config %q: %w", entry.Name, err)
+		}
+		desired = append(desired, cfg)
+	}
+	return desired, nil
+}
+
+func resolveSecretReference(
+	ref secretReference,
+	baseDir string,
+	lookupEnv func(string) (string, bool),
+	readFile func(string) ([]byte, error),
+) (string, error) {
+	switch {
+	case ref.FromEnv != "" && ref.FromFile != "":
+		return "", errors.New("secret must set only one of fromEnv and fromFile")
+	case ref.FromEnv != "":
+		value, ok := lookupEnv(ref.FromEnv)
+		if !ok || value == "" {
+			return "", fmt.Errorf("secret environment variable %s is not set", ref.FromEnv)
+		}
+		return strings.TrimSpace(value), nil
+	case ref.FromFile != "":
+		path := ref.FromFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		body, err := readFile(path)
+		if err != nil {
+			return "", errors.Wrap(err, "reading secret file")
+		}
+		return strings.TrimSpace(string(body)), nil
+	default:
+		return "", errors.New("secret must set fromEnv or fromFile")
+	}
+}
+
+func planConfigs(current []webhooks.Config, desired []desiredConfig) (configsPlan, error) {
+	byName := map[string]webhooks.Config{}
+	for _, cfg := range current {
+		if cfg.Name == "" {
+			continue
+		}
+		if _, ok := byName[cfg.Name]; ok {
+			return configsPlan{}, fmt.Errorf("several configs are named %q, rename or delete one before applying", cfg.Name)
+		}
+		byName[cfg.Name] = cfg
+	}
+
+	plan := configsPlan{}
+	for _, want := range desired {
+		existing, ok := byName[want.Name]
+		if !ok {
+			plan.creates = append(plan.creates, want.ConfigUser)
+			continue
+		}
+		delete(byName, want.Name)
+
+		update := want.ConfigUser
+		update.MaintenanceWindows = existing.MaintenanceWindows
+		update.CaptureAttempts = existing.CaptureAttempts
+		update.Priority = existing.Priority
+		update.EventTypePriorities = existing.EventTypePriorities
+		update.Monitor = existing.Monitor
+		update.OwnerContactURL = existing.OwnerContactURL
+		if !want.manageSecret {
+			update.Secret = existing.Secret
+		}
+		changes := make([]string, 0, 3)
+		if update.Endpoint != existing.Endpoint {
+			changes = append(changes, "endpoint")
+		}
+		if !sameEventTypes(update.EventTypes, existing.EventTypes) {
+			changes = append(changes, "eventTypes")
+		}
+		if update.Secret != existing.Secret {
+			changes = append(changes, "secret")
+		}
+		if len(changes) > 0 {
+			plan.updates = append(plan.updates, configUpdate{id: existing.ID, config: update, changes: changes})
+		}
+	}
+	for _, cfg := range byName {
+		plan.deletes = append(plan.deletes, cfg)
+	}
+	sort.Slice(plan.deletes, func(i, j int) bool { return plan.deletes[i].Name < plan.deletes[j].Name })
+	return plan, nil
+}
+
+func sameEventTypes(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func printConfigsPlan(w io.Writer, plan configsPlan) {
+	for _, cfg := range plan.creates {
+		_, _ = fmt.Fprintf(w, "+ create %s (%s)\n", cfg.Name, cfg.Endpoint)
+	}
+	for _, update := range plan.updates {
+		_, _ = fmt.Fprintf(w, "~ update %s: %s\n", update.config.Name, strings.Join(update.changes, ", "))
+	}
+	for _, cfg := range plan.deletes {
+		_, _ = fmt.Fprintf(w, "- delete %s (%s)\n", cfg.Name, cfg.ID)
+	}
+	_, _ = fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete.\n",
+		len(plan.creates), len(plan.updates), len(plan.deletes))
+}
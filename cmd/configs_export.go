@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/formancehq/go-libs/v2/bun/bunconnect"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	configsExportOutputFlag     = "output"
+	configsExportDeliveriesFlag = "with-pending-deliveries"
+	configsPassphraseEnvFlag    = "passphrase-env"
+	configsPassphraseFileFlag   = "passphrase-file"
+)
+
+func newConfigsExportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "export",
+		Short: "Export all webhook configs to a versioned JSON archive",
+		Long: "Export all webhook configs to a versioned JSON archive.\n\n" +
+			"Secrets are encrypted when a passphrase is given through --passphrase-env or " +
+			"--passphrase-file, and written in clear text otherwise.",
+		Args: cobra.NoArgs,
+		RunE: runConfigsExport,
+	}
+	bunconnect.AddFlags(command.Flags())
+	command.Flags().StringP(configsExportOutputFlag, "o", "-", "path of the archive to write, - for stdout")
+	command.Flags().Bool(configsExportDeliveriesFlag, false, "include pending and in-flight deliveries")
+	addArchivePassphraseFlags(command)
+	return command
+}
+
+func addArchivePassphraseFlags(command *cobra.Command) {
+	command.Flags().String(configsPassphraseEnvFlag, "", "environment variable holding the archive passphrase")
+	command.Flags().String(configsPassphraseFileFlag, "", "file holding the archive passphrase")
+}
+
+func readArchivePassphrase(cmd *cobra.Command) (string, error) {
+	ref := secretReference{}
+	ref.FromEnv, _ = cmd.Flags().GetString(configsPassphraseEnvFlag)
+	ref.FromFile, _ = cmd.Flags().GetString(configsPassphraseFileFlag)
+	if ref.FromEnv == "" && ref.FromFile == "" {
+		return "", nil
+	}
+	passphrase, err := resolveSecretReference(ref, "", os.LookupEnv, os.ReadFile)
+	return passphrase, errors.Wrap(err, "reading archive passphrase")
+}
+
+func runConfigsExport(cmd *cobra.Command, _ []string) error {
+	output, _ := cmd.Flags().GetString(configsExportOutputFlag)
+	withDeliveries, _ := cmd.Flags().GetBool(configsExportDeliveriesFlag)
+	passphrase, err := readArchivePassphrase(cmd)
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "Warning: no passphrase given, secrets are exported in clear text.")
+	}
+
+	store, closeStore, err := openConfigsStore(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	configs, err := store.FindManyConfigs(cmd.Context(), map[string]any{})
+	if err != nil {
+		return err
+	}
+	deliveries := []webhooks.Delivery{}
+	if withDeliveries {
+		if deliveries, err = findInFlightDeliveries(cmd, store, configs); err != nil {
+			return err
+		}
+	}
+	archive, err := webhooks.NewConfigArchive(configs, deliveries, passphrase)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = cmd.OutOrStdout()
+	if output != "-" {
+		file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return errors.Wrap(err, "creating archive")
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		return errors.Wrap(err, "writing archive")
+	}
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d configs and %d deliveries.\n",
+		len(archive.Configs), len(archive.Deliveries))
+	return nil
+}
+
+// findInFlightDeliveries returns the deliveries of the exported configs that
+// have not reached a final status yet.
+func findInFlightDeliveries(cmd *cobra.Command, store storage.Store, configs []webhooks.Config) ([]webhooks.Delivery, error) {
+	res := []webhooks.Delivery{}
+	for _, cfg := range configs {
+		for _, status := range []string{webhooks.StatusDeliveryPending, webhooks.StatusDeliveryDelivering} {
+			filter := webhooks.DeliveryFilter{ConfigID: cfg.ID, Status: status}
+			for {
+				page, err := store.FindDeliveries(cmd.Context(), filter)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, page.Data...)
+				if !page.HasMore {
+					break
+				}
+				filter.After = page.NextCursor
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/formancehq/go-libs/v2/bun/bunconnect"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	configsImportFileFlag        = "file"
+	configsImportPreserveIDsFlag = "preserve-ids"
+)
+
+func newConfigsImportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "import",
+		Short: "Import webhook configs from an archive produced by configs export",
+		Long: "Import webhook configs from an archive produced by configs export.\n\n" +
+			"IDs are remapped by default. Remapped IDs only depend on the archive, so " +
+			"importing the same archive again does not create duplicates.",
+		Args: cobra.NoArgs,
+		RunE: runConfigsImport,
+	}
+	bunconnect.AddFlags(command.Flags())
+	command.Flags().StringP(configsImportFileFlag, "f", "", "path of the archive to import")
+	command.Flags().Bool(configsImportPreserveIDsFlag, false, "keep the config and delivery IDs of the archive")
+	addArchivePassphraseFlags(command)
+	_ = command.MarkFlagRequired(configsImportFileFlag)
+	return command
+}
+
+func runConfigsImport(cmd *cobra.Command, _ []string) error {
+	path, _ := cmd.Flags().GetString(configsImportFileFlag)
+	preserveIDs, _ := cmd.Flags().GetBool(configsImportPreserveIDsFlag)
+	passphrase, err := readArchivePassphrase(cmd)
+	if err != nil {
+		return err
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading archive")
+	}
+	archive := webhooks.ConfigArchive{}
+	if err := json.Unmarshal(body, &archive); err != nil {
+		return errors.Wrap(err, "decoding archive")
+	}
+	configs, deliveries, err := archive.Restore(passphrase, preserveIDs)
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openConfigsStore(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	result, err := store.ImportConfigs(cmd.Context(), configs, deliveries)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Imported %d configs (%d already present) and %d deliveries (%d already present).\n",
+		result.ConfigsImported, result.ConfigsSkipped, result.DeliveriesImported, result.DeliveriesSkipped)
+	return nil
+}
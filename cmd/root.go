@@ -16,6 +16,7 @@ func NewRootCommand() *cobra.Command {
 	root.AddCommand(newVersionCommand())
 	root.AddCommand(newMigrateCommand())
 	root.AddCommand(newBackfillDeliveriesCommand())
+	root.AddCommand(newConfigsCommand())
 
 	return root
 }
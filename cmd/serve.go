@@ -76,6 +76,19 @@ func serve(cmd *cobra.Command, _ []string) error {
 		abortAfter, _ := cmd.Flags().GetDuration(flag.AbortAfter)
 		maxAttempts, _ := cmd.Flags().GetInt(flag.MaxAttempts)
 		topics, _ := cmd.Flags().GetStringSlice(flag.KafkaTopics)
+		deadLetterTopic, _ := cmd.Flags().GetString(flag.DeadLetterTopic)
+		configMetrics, err := configMetricsConfigFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		failures, err := failurePolicyConfigFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		claims, err := claimConfigFromFlags(cmd)
+		if err != nil {
+			return err
+		}
 		options = append(options, worker.StartModule(
 			cmd,
 			retryPeriod,
@@ -86,8 +99,12 @@ func serve(cmd *cobra.Command, _ []string) error {
 				maxAttempts,
 			),
 			retryBatchSize,
+			claims,
 			topics,
 			retentionConfigFromFlags(cmd),
+			configMetrics,
+			failures,
+			deadLetterTopic,
 		))
 	}
 
@@ -9,6 +9,7 @@ import (
 	"github.com/formancehq/go-libs/v2/aws/iam"
 	"github.com/formancehq/go-libs/v2/publish"
 
+	"github.com/formancehq/webhooks/pkg/health"
 	"github.com/formancehq/webhooks/pkg/storage/postgres"
 
 	"github.com/formancehq/go-libs/v2/bun/bunconnect"
@@ -70,8 +71,21 @@ func workerServiceOptions(cmd *cobra.Command) ([]fx.Option, error) {
 	abortAfter, _ := cmd.Flags().GetDuration(flag.AbortAfter)
 	maxAttempts, _ := cmd.Flags().GetInt(flag.MaxAttempts)
 	topics, _ := cmd.Flags().GetStringSlice(flag.KafkaTopics)
+	deadLetterTopic, _ := cmd.Flags().GetString(flag.DeadLetterTopic)
 	listen, _ := cmd.Flags().GetString(flag.Listen)
 	retention := retentionConfigFromFlags(cmd)
+	configMetrics, err := configMetricsConfigFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := failurePolicyConfigFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := claimConfigFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	return []fx.Option{
 		innerotlp.HttpClientModule(),
@@ -92,8 +106,12 @@ func workerServiceOptions(cmd *cobra.Command) ([]fx.Option, error) {
 				maxAttempts,
 			),
 			retryBatchSize,
+			claims,
 			topics,
 			retention,
+			configMetrics,
+			failures,
+			deadLetterTopic,
 		),
 	}, nil
 }
@@ -102,8 +120,8 @@ func workerHTTPServerModule(cmd *cobra.Command, listen string) fx.Option {
 	debug := service.IsDebug(cmd)
 
 	return fx.Options(
-		fx.Provide(func() http.Handler {
-			return worker.NewWorkerHandler(debug)
+		fx.Provide(func(dispatcher *worker.DeliveryDispatcher, checks health.Checks) http.Handler {
+			return worker.NewWorkerHandler(debug, dispatcher, checks)
 		}),
 		fx.Invoke(func(lc fx.Lifecycle, h http.Handler) {
 			lc.Append(httpserver.NewHook(h, httpserver.WithAddress(listen)))
@@ -111,6 +129,19 @@ func workerHTTPServerModule(cmd *cobra.Command, listen string) fx.Option {
 	)
 }
 
+func claimConfigFromFlags(cmd *cobra.Command) (worker.ClaimConfig, error) {
+	leaseDuration, _ := cmd.Flags().GetDuration(flag.ClaimLeaseDuration)
+	recoveryInterval, _ := cmd.Flags().GetDuration(flag.ClaimRecoveryInterval)
+	drainTimeout, _ := cmd.Flags().GetDuration(flag.DrainTimeout)
+	cfg := worker.ClaimConfig{
+		LeaseDuration:    leaseDuration,
+		RecoveryInterval: recoveryInterval,
+		Version:          Version,
+		DrainTimeout:     drainTimeout,
+	}
+	return cfg, cfg.Validate()
+}
+
 func retentionConfigFromFlags(cmd *cobra.Command) worker.RetentionConfig {
 	period, _ := cmd.Flags().GetDuration(flag.RetentionPeriod)
 	successDelay, _ := cmd.Flags().GetDuration(flag.RetentionSuccessDelay)
@@ -121,3 +152,31 @@ func retentionConfigFromFlags(cmd *cobra.Command) worker.RetentionConfig {
 		FailedDelay:  failedDelay,
 	}
 }
+
+func configMetricsConfigFromFlags(cmd *cobra.Command) (worker.ConfigMetricsConfig, error) {
+	mode, _ := cmd.Flags().GetString(flag.ConfigMetrics)
+	limit, _ := cmd.Flags().GetInt(flag.ConfigMetricsLimit)
+	cfg := worker.ConfigMetricsConfig{
+		Mode:  mode,
+		Limit: limit,
+	}
+	return cfg, cfg.Validate()
+}
+
+func failurePolicyConfigFromFlags(cmd *cobra.Command) (worker.FailurePolicyConfig, error) {
+	consecutiveFailures, _ := cmd.Flags().GetInt(flag.DisableAfterFailures)
+	failureRate, _ := cmd.Flags().GetFloat64(flag.DisableFailureRate)
+	window, _ := cmd.Flags().GetDuration(flag.DisableFailureWindow)
+	minDeliveries, _ := cmd.Flags().GetInt(flag.DisableFailureMinDeliveries)
+	action, _ := cmd.Flags().GetString(flag.DisableAction)
+	topic, _ := cmd.Flags().GetString(flag.DisableNotificationsTopic)
+	cfg := worker.FailurePolicyConfig{
+		ConsecutiveFailures: consecutiveFailures,
+		FailureRate:         failureRate,
+		Window:              window,
+		MinDeliveries:       minDeliveries,
+		Action:              action,
+		Topic:               topic,
+	}
+	return cfg, cfg.Validate()
+}
@@ -0,0 +1,243 @@
+package webhooks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	ConfigArchiveVersion = 1
+
+	archiveEncryptionAlgorithm  = "pbkdf2-sha256/aes-256-gcm"
+	archiveEncryptionIterations = 600_000
+)
+
+var ErrInvalidArchivePassphrase = errors.New("archive passphrase is invalid")
+
+// ConfigArchive is the portable representation of the configs of a stack,
+// optionally with their in-flight deliveries. Its format is versioned so that
+// an archive can be imported by a later release.
+type ConfigArchive struct {
+	Version int `json:"version"`
+	// ID is unique per export. Remapped IDs are derived from it, so importing
+	// the same archive twice yields the same IDs.
+	ID         string             `json:"id"`
+	ExportedAt time.Time          `json:"exportedAt"`
+	Encryption *ArchiveEncryption `json:"encryption,omitempty"`
+	Configs    []ArchivedConfig   `json:"configs"`
+	Deliveries []ArchivedDelivery `json:"deliveries,omitempty"`
+}
+
+type ArchiveEncryption struct {
+	Algorithm  string `json:"algorithm"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+}
+
+type ArchivedConfig struct {
+	ID                  string              `json:"id"`
+	Name                string              `json:"name,omitempty"`
+	Endpoint            string              `json:"endpoint"`
+	Secret              string              `json:"secret,omitempty"`
+	EncryptedSecret     string              `json:"encryptedSecret,omitempty"`
+	EventTypes          []string            `json:"eventTypes"`
+	Active              bool                `json:"active"`
+	MaintenanceWindows  []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	CaptureAttempts     bool                `json:"captureAttempts,omitempty"`
+	Priority            string              `json:"priority,omitempty"`
+	EventTypePriorities map[string]string   `json:"eventTypePriorities,omitempty"`
+	Monitor             bool                `json:"monitor,omitempty"`
+	OwnerContactURL     string              `json:"ownerContactUrl,omitempty"`
+	CreatedAt           time.Time           `json:"createdAt"`
+	UpdatedAt           time.Time           `json:"updatedAt"`
+}
+
+type ArchivedDelivery struct {
+	ID               string     `json:"id"`
+	EventID          string     `json:"eventID"`
+	IdempotencyKey   string     `json:"idempotencyKey,omitempty"`
+	ConfigID         string     `json:"configID"`
+	EventType        string     `json:"eventType"`
+	Payload          string     `json:"payload"`
+	Priority         string     `json:"priority,omitempty"`
+	AttemptCount     int        `json:"attemptCount"`
+	ReplayGeneration int        `json:"replayGeneration"`
+	CycleStartedAt   *time.Time `json:"cycleStartedAt,omitempty"`
+	NextAttemptAt    *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+type ConfigImportResult struct {
+	ConfigsImported    int `json:"configsImported"`
+	ConfigsSkipped     int `json:"configsSkipped"`
+	DeliveriesImported int `json:"deliveriesImported"`
+	DeliveriesSkipped  int `json:"deliveriesSkipped"`
+}
+
+// NewConfigArchive builds an archive of the given configs and deliveries.
+// Secrets are encrypted when a passphrase is given and stored in clear text
+// otherwise. Deliveries are archived as pending, whatever their current claim.
+func NewConfigArchive(configs []Config, deliveries []Delivery, passphrase string) (ConfigArchive, error) {
+	archive := ConfigArchive{
+		Version:    ConfigArchiveVersion,
+		ID:         uuid.NewString(),
+		ExportedAt: time.Now().UTC(),
+		Configs:    make([]ArchivedConfig, 0, len(configs)),
+	}
+	var gcm cipher.AEAD
+	if passphrase != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return ConfigArchive{}, errors.Wrap(err, "generating archive salt")
+		}
+		archive.Encryption = &ArchiveEncryption{
+			Algorithm:  archiveEncryptionAlgorithm,
+			Iterations: archiveEncryptionIterations,
+			Salt:       base64.StdEncoding.EncodeToString(salt),
+		}
+		var err error
+		if gcm, err = archive.Encryption.cipher(passphrase); err != nil {
+			return ConfigArchive{}, err
+		}
+	}
+	for _, cfg := range configs {
+		archived := ArchivedConfig{
+			ID: cfg.ID, Name: cfg.Name, Endpoint: cfg.Endpoint, EventTypes: cfg.EventTypes,
+			Active: cfg.Active, CreatedAt: cfg.CreatedAt, UpdatedAt: cfg.UpdatedAt,
+			MaintenanceWindows: cfg.MaintenanceWindows, CaptureAttempts: cfg.CaptureAttempts,
+			Priority: cfg.Priority, EventTypePriorities: cfg.EventTypePriorities, Monitor: cfg.Monitor,
+			OwnerContactURL: cfg.OwnerContactURL,
+		}
+		if gcm == nil {
+			archived.Secret = cfg.Secret
+		} else {
+			nonce := make([]byte, gcm.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return ConfigArchive{}, errors.Wrap(err, "generating secret nonce")
+			}
+			sealed := gcm.Seal(nonce, nonce, []byte(cfg.Secret), []byte(cfg.ID))
+			archived.EncryptedSecret = base64.StdEncoding.EncodeToString(sealed)
+		}
+		archive.Configs = append(archive.Configs, archived)
+	}
+	for _, delivery := range deliveries {
+		archive.Deliveries = append(archive.Deliveries, ArchivedDelivery{
+			ID: delivery.ID, EventID: delivery.EventID, IdempotencyKey: delivery.IdempotencyKey,
+			ConfigID: delivery.ConfigID, EventType: delivery.EventType, Payload: delivery.Payload,
+			AttemptCount: delivery.AttemptCount, ReplayGeneration: delivery.ReplayGeneration,
+			CycleStartedAt: delivery.CycleStartedAt, NextAttemptAt: delivery.NextAttemptAt,
+			Priority: delivery.Priority, CreatedAt: delivery.CreatedAt,
+		})
+	}
+	return archive, nil
+}
+
+// Restore decodes the archive into configs and pending deliveries ready to be
+// inserted. Unless preserveIDs is set, every ID is replaced by one derived from
+// the archive ID and the original ID.
+func (a ConfigArchive) Restore(passphrase string, preserveIDs bool) ([]Config, []Delivery, error) {
+	if a.Version != ConfigArchiveVersion {
+		return nil, nil, fmt.Errorf("unsupported archive version %d", a.Version)
+	}
+	archiveID, err := uuid.Parse(a.ID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid archive id")
+	}
+	mapID := func(kind, id string) string {
+		if preserveIDs {
+			return id
+		}
+		return uuid.NewSHA1(archiveID, []byte(kind+":"+id)).String()
+	}
+
+	var gcm cipher.AEAD
+	if a.Encryption != nil {
+		if passphrase == "" {
+			return nil, nil, errors.New("archive secrets are encrypted: a passphrase is required")
+		}
+		if gcm, err = a.Encryption.cipher(passphrase); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	configIDs := make(map[string]string, len(a.Configs))
+	configs := make([]Config, 0, len(a.Configs))
+	for _, archived := range a.Configs {
+		secret := archived.Secret
+		if gcm != nil {
+			sealed, err := base64.StdEncoding.DecodeString(archived.EncryptedSecret)
+			if err != nil || len(sealed) < gcm.NonceSize() {
+				return nil, nil, fmt.Errorf("config %s: malformed encrypted secret", archived.ID)
+			}
+			plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(archived.ID))
+			if err != nil {
+				return nil, nil, ErrInvalidArchivePassphrase
+			}
+			secret = string(plain)
+		}
+		cfg := Config{
+			ConfigUser: ConfigUser{
+				Name: archived.Name, Endpoint: archived.Endpoint, Secret: secret, EventTypes: archived.EventTypes,
+				MaintenanceWindows: archived.MaintenanceWindows, CaptureAttempts: archived.CaptureAttempts,
+				Priority: archived.Priority, EventTypePriorities: archived.EventTypePriorities,
+				Monitor: archived.Monitor, OwnerContactURL: archived.OwnerContactURL,
+			},
+			ID: mapID("config", archived.ID), Active: archived.Active,
+			CreatedAt: archived.CreatedAt, UpdatedAt: archived.UpdatedAt,
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("config %s: %w", archived.ID, err)
+		}
+		cfg.ScheduleMaintenance(time.Now().UTC())
+		configIDs[archived.ID] = cfg.ID
+		configs = append(configs, cfg)
+	}
+
+	deliveries := make([]Delivery, 0, len(a.Deliveries))
+	for _, archived := range a.Deliveries {
+		configID, ok := configIDs[archived.ConfigID]
+		if !ok {
+			return nil, nil, fmt.Errorf("delivery %s: unknown config %s", archived.ID, archived.ConfigID)
+		}
+		nextAttemptAt := archived.NextAttemptAt
+		if nextAttemptAt == nil {
+			nextAttemptAt = &a.ExportedAt
+		}
+		deliveries = append(deliveries, Delivery{
+			ID: mapID("delivery", archived.ID), EventID: archived.EventID, IdempotencyKey: archived.IdempotencyKey,
+			ConfigID: configID, EventType: archived.EventType, Payload: archived.Payload,
+			Status: StatusDeliveryPending, Priority: archived.Priority, AttemptCount: archived.AttemptCount,
+			ReplayGeneration: archived.ReplayGeneration, CycleStartedAt: archived.CycleStartedAt,
+			NextAttemptAt: nextAttemptAt, CreatedAt: archived.CreatedAt, UpdatedAt: a.ExportedAt,
+		})
+	}
+	return configs, deliveries, nil
+}
+
+func (e ArchiveEncryption) cipher(passphrase string) (cipher.AEAD, error) {
+	if e.Algorithm != archiveEncryptionAlgorithm {
+		return nil, fmt.Errorf("unsupported archive encryption %q", e.Algorithm)
+	}
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding archive salt")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, e.Iterations, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving archive key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating archive cipher")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,87 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func archiveFixtures() ([]webhooks.Config, []webhooks.Delivery) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	config := webhooks.Config{
+		ConfigUser: webhooks.ConfigUser{
+			Name: "orders", Endpoint: "https://example.com/orders", Secret: webhooks.NewSecret(),
+			EventTypes: []string{"ledger.committed_transactions"},
+		},
+		ID: "2d6cfc7f-5cbb-4fb4-8c65-1b7e0d4ac8b9", Active: true, CreatedAt: now, UpdatedAt: now,
+	}
+	claimedAt := now
+	delivery := webhooks.Delivery{
+		ID: "b0c5f5fd-4c0b-4cc4-9d4f-61ad4e3fe9b6", EventID: "event-1", ConfigID: config.ID,
+		EventType: "ledger.committed_transactions", Payload: `{"type":"ledger.committed_transactions"}`,
+		Status: webhooks.StatusDeliveryDelivering, AttemptCount: 2, ClaimedAt: &claimedAt,
+		CycleStartedAt: &now, NextAttemptAt: &now, CreatedAt: now, UpdatedAt: now,
+	}
+	return []webhooks.Config{config}, []webhooks.Delivery{delivery}
+}
+
+func roundTripArchive(t *testing.T, archive webhooks.ConfigArchive) webhooks.ConfigArchive {
+	t.Helper()
+	body, err := json.Marshal(archive)
+	require.NoError(t, err)
+	decoded := webhooks.ConfigArchive{}
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	return decoded
+}
+
+func TestConfigArchiveEncryptsSecrets(t *testing.T) {
+	configs, deliveries := archiveFixtures()
+	archive, err := webhooks.NewConfigArchive(configs, deliveries, "correct horse")
+	require.NoError(t, err)
+	archive = roundTripArchive(t, archive)
+	require.Empty(t, archive.Configs[0].Secret)
+	require.NotEmpty(t, archive.Configs[0].EncryptedSecret)
+
+	_, _, err = archive.Restore("", true)
+	require.Error(t, err)
+	_, _, err = archive.Restore("wrong passphrase", true)
+	require.ErrorIs(t, err, webhooks.ErrInvalidArchivePassphrase)
+
+	restored, restoredDeliveries, err := archive.Restore("correct horse", true)
+	require.NoError(t, err)
+	require.Equal(t, configs[0].Secret, restored[0].Secret)
+	require.Equal(t, configs[0].ID, restored[0].ID)
+	require.Equal(t, deliveries[0].ID, restoredDeliveries[0].ID)
+	require.Equal(t, webhooks.StatusDeliveryPending, restoredDeliveries[0].Status, "in-flight claims are not carried over")
+	require.Nil(t, restoredDeliveries[0].ClaimedAt)
+	require.Equal(t, 2, restoredDeliveries[0].AttemptCount)
+}
+
+func TestConfigArchiveRemapsIDsDeterministically(t *testing.T) {
+	configs, deliveries := archiveFixtures()
+	archive, err := webhooks.NewConfigArchive(configs, deliveries, "")
+	require.NoError(t, err)
+	archive = roundTripArchive(t, archive)
+	require.Equal(t, configs[0].Secret, archive.Configs[0].Secret)
+
+	first, firstDeliveries, err := archive.Restore("", false)
+	require.NoError(t, err)
+	second, secondDeliveries, err := archive.Restore("", false)
+	require.NoError(t, err)
+	require.NotEqual(t, configs[0].ID, first[0].ID)
+	require.Equal(t, first[0].ID, second[0].ID, "a retried import must produce the same IDs")
+	require.Equal(t, firstDeliveries[0].ID, secondDeliveries[0].ID)
+	require.Equal(t, first[0].ID, firstDeliveries[0].ConfigID)
+}
+
+func TestConfigArchiveRejectsUnknownVersion(t *testing.T) {
+	configs, deliveries := archiveFixtures()
+	archive, err := webhooks.NewConfigArchive(configs, deliveries, "")
+	require.NoError(t, err)
+	archive.Version = webhooks.ConfigArchiveVersion + 1
+	_, _, err = archive.Restore("", true)
+	require.ErrorContains(t, err, "unsupported archive version")
+}
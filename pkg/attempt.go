@@ -86,23 +86,25 @@ const (
 type Attempt struct {
 	bun.BaseModel `bun:"table:attempts"`
 
-	ID              string        `json:"id" bun:",pk"`
-	WebhookID       string        `json:"webhookID" bun:"webhook_id"`
-	CreatedAt       time.Time     `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt       time.Time     `json:"updatedAt" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
-	Config          Config        `json:"config" bun:"type:jsonb"`
-	Payload         string        `json:"payload"`
-	StatusCode      int           `json:"statusCode" bun:"status_code"`
-	RetryAttempt    int           `json:"retryAttempt" bun:"retry_attempt"`
-	Status          string        `json:"status"`
-	NextRetryAfter  time.Time     `json:"nextRetryAfter,omitempty" bun:"next_retry_after,nullzero"`
-	ResponseExcerpt string        `json:"-" bun:"-"`
-	DeliveryError   string        `json:"-" bun:"-"`
-	Duration        time.Duration `json:"-" bun:"-"`
+	ID              string          `json:"id" bun:",pk"`
+	WebhookID       string          `json:"webhookID" bun:"webhook_id"`
+	CreatedAt       time.Time       `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt       time.Time       `json:"updatedAt" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	Config          Config          `json:"config" bun:"type:jsonb"`
+	Payload         string          `json:"payload"`
+	StatusCode      int             `json:"statusCode" bun:"status_code"`
+	RetryAttempt    int             `json:"retryAttempt" bun:"retry_attempt"`
+	Status          string          `json:"status"`
+	NextRetryAfter  time.Time       `json:"nextRetryAfter,omitempty" bun:"next_retry_after,nullzero"`
+	ResponseExcerpt string          `json:"-" bun:"-"`
+	DeliveryError   string          `json:"-" bun:"-"`
+	Duration        time.Duration   `json:"-" bun:"-"`
+	Capture         *AttemptCapture `json:"-" bun:"-"`
 }
 
 type attemptOptions struct {
 	firstAttemptAt time.Time
+	capture        bool
 }
 
 type AttemptOption func(*attemptOptions)
@@ -118,6 +120,14 @@ func WithFirstAttemptAt(firstAttemptAt time.Time) AttemptOption {
 	}
 }
 
+// WithCapture records the request headers, body hash, response headers and
+// network timings of the attempt in Attempt.Capture.
+func WithCapture(capture bool) AttemptOption {
+	return func(opts *attemptOptions) {
+		opts.capture = capture
+	}
+}
+
 func MakeAttempt(ctx context.Context, httpClient *http.Client, retryPolicy BackoffPolicy, id, webhookID string, attemptNb int, cfg Config, idempotencyKey string, payload []byte, isTest bool, opts ...AttemptOption) (Attempt, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Endpoint, bytes.NewBuffer(payload))
 	if err != nil {
@@ -134,25 +144,26 @@ func MakeAttempt(ctx context.Context, httpClient *http.Client, retryPolicy Backo
 		options.firstAttemptAt = requestTime
 	}
 
-	timestamp := requestTime.Unix()
-	signature, err := security.Sign(webhookID, timestamp, cfg.Secret, payload)
-	if err != nil {
-		return Attempt{}, errors.Wrap(err, "security.Sign")
+	if err := signRequest(req, webhookID, requestTime.Unix(), cfg.Secret, payload, isTest); err != nil {
+		return Attempt{}, err
 	}
-
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("user-agent", "formance-webhooks/v0")
-	req.Header.Set("formance-webhook-id", webhookID)
-	req.Header.Set("formance-webhook-timestamp", fmt.Sprintf("%d", timestamp))
-	req.Header.Set("formance-webhook-signature", signature)
-	req.Header.Set("formance-webhook-test", fmt.Sprintf("%v", isTest))
 	if idempotencyKey != "" {
 		req.Header.Set("formance-webhook-idempotency-key", idempotencyKey)
 	}
 
+	var recorder *attemptRecorder
+	if options.capture {
+		recorder = newAttemptRecorder(payload)
+		req = req.WithContext(recorder.trace(req.Context()))
+	}
+
 	start := time.Now()
 	resp, doErr := httpClient.Do(req)
 	decisionTime := time.Now().UTC()
+	var capture *AttemptCapture
+	if recorder != nil {
+		capture = recorder.finish(resp)
+	}
 
 	attempt, err := classifyResponse(ctx, resp, doErr, retryPolicy, attemptNb, decisionTime, options.firstAttemptAt, Attempt{
 		ID:           id,
@@ -165,6 +176,7 @@ func MakeAttempt(ctx context.Context, httpClient *http.Client, retryPolicy Backo
 		return Attempt{}, err
 	}
 	attempt.Duration = time.Since(start)
+	attempt.Capture = capture
 	if doErr != nil {
 		attempt.DeliveryError = doErr.Error()
 	}
@@ -173,6 +185,23 @@ func MakeAttempt(ctx context.Context, httpClient *http.Client, retryPolicy Backo
 	return attempt, nil
 }
 
+// signRequest sets the headers every outbound webhook carries, signature
+// included.
+func signRequest(req *http.Request, webhookID string, timestamp int64, secret string, payload []byte, isTest bool) error {
+	signature, err := security.Sign(webhookID, timestamp, secret, payload)
+	if err != nil {
+		return errors.Wrap(err, "security.Sign")
+	}
+
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("user-agent", "formance-webhooks/v0")
+	req.Header.Set("formance-webhook-id", webhookID)
+	req.Header.Set("formance-webhook-timestamp", fmt.Sprintf("%d", timestamp))
+	req.Header.Set("formance-webhook-signature", signature)
+	req.Header.Set("formance-webhook-test", fmt.Sprintf("%v", isTest))
+	return nil
+}
+
 // classifyResponse turns the result of the delivery HTTP call into a persisted
 // Attempt with its final status. base carries the identity fields already set by
 // the caller; doErr is the transport error (if any).
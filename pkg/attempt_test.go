@@ -2,11 +2,15 @@ package webhooks_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -403,3 +407,47 @@ func TestMakeAttempt_ClampsAbsurdRetryAfter(t *testing.T) {
 	assert.LessOrEqual(t, attempt.NextRetryAfter.Sub(before), 7*time.Hour,
 		"an endpoint-controlled Retry-After must not park the delivery years in the future")
 }
+
+func TestMakeAttempt_CaptureRecordsRedactedExchange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Receiver", "ok")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := webhooks.Config{
+		ConfigUser: webhooks.ConfigUser{
+			Endpoint:   server.URL,
+			Secret:     webhooks.NewSecret(),
+			EventTypes: []string{"test.event"},
+		},
+		ID:     "cfg-capture",
+		Active: true,
+	}
+	payload := []byte(`{"type":"test.event"}`)
+
+	attempt, err := webhooks.MakeAttempt(
+		context.Background(), server.Client(), &fixedBackoff{}, "attempt-id", "webhook-id", 0, cfg, "", payload, false,
+		webhooks.WithCapture(true),
+	)
+	require.NoError(t, err)
+	require.NotNil(t, attempt.Capture)
+	assert.Equal(t, "webhook-id", attempt.Capture.RequestHeaders.Get("formance-webhook-id"))
+	assert.NotEmpty(t, attempt.Capture.RequestHeaders.Get("formance-webhook-signature"))
+	// Headers added by the transport are captured as sent.
+	assert.Equal(t, strings.TrimPrefix(server.URL, "http://"), attempt.Capture.RequestHeaders.Get("Host"))
+	assert.NotEmpty(t, attempt.Capture.RequestHeaders.Get("User-Agent"))
+	assert.Equal(t, strconv.Itoa(len(payload)), attempt.Capture.RequestHeaders.Get("Content-Length"))
+	sum := sha256.Sum256(payload)
+	assert.Equal(t, hex.EncodeToString(sum[:]), attempt.Capture.BodySHA256)
+	assert.Equal(t, "ok", attempt.Capture.ResponseHeaders.Get("X-Receiver"))
+	assert.Equal(t, "[REDACTED]", attempt.Capture.ResponseHeaders.Get("Set-Cookie"))
+	assert.NotNil(t, attempt.Capture.Timings.TTFBMillis)
+
+	attempt, err = webhooks.MakeAttempt(
+		context.Background(), server.Client(), &fixedBackoff{}, "attempt-id", "webhook-id", 0, cfg, "", payload, false,
+	)
+	require.NoError(t, err)
+	assert.Nil(t, attempt.Capture, "capture is opt-in per config")
+}
@@ -0,0 +1,154 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+const redactedHeaderValue = "[REDACTED]"
+
+// redactedHeaders never leave the worker in a capture: they carry credentials
+// of the receiver or of a proxy rather than anything we signed.
+var redactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// AttemptCapture is the detailed exchange of one delivery attempt, recorded for
+// configs with CaptureAttempts enabled.
+type AttemptCapture struct {
+	RequestHeaders  http.Header    `json:"requestHeaders"`
+	BodySHA256      string         `json:"bodySHA256"`
+	ResponseHeaders http.Header    `json:"responseHeaders,omitempty"`
+	Timings         AttemptTimings `json:"timings"`
+}
+
+// AttemptTimings breaks an attempt down into its network phases. Phases that
+// did not happen, such as DNS on a reused connection, are omitted.
+type AttemptTimings struct {
+	ReusedConnection bool   `json:"reusedConnection"`
+	DNSMillis        *int64 `json:"dnsMillis,omitempty"`
+	ConnectMillis    *int64 `json:"connectMillis,omitempty"`
+	TLSMillis        *int64 `json:"tlsMillis,omitempty"`
+	TTFBMillis       *int64 `json:"ttfbMillis,omitempty"`
+}
+
+// attemptRecorder collects an AttemptCapture through httptrace hooks, which
+// may be called from transport goroutines.
+type attemptRecorder struct {
+	mu sync.Mutex
+
+	start        time.Time
+	capture      AttemptCapture
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+}
+
+func newAttemptRecorder(body []byte) *attemptRecorder {
+	sum := sha256.Sum256(body)
+	return &attemptRecorder{capture: AttemptCapture{
+		RequestHeaders: http.Header{},
+		BodySHA256:     hex.EncodeToString(sum[:]),
+	}}
+}
+
+func (r *attemptRecorder) trace(ctx context.Context) context.Context {
+	r.start = time.Now()
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.capture.Timings.ReusedConnection = info.Reused
+			// The transport may retry on another connection: only the headers
+			// of the last write were sent.
+			r.capture.RequestHeaders = http.Header{}
+		},
+		// Headers are recorded as written, with those the transport adds
+		// such as Host, User-Agent and Content-Length.
+		WroteHeaderField: func(key string, value []string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			key = http.CanonicalHeaderKey(key)
+			r.capture.RequestHeaders[key] = append(r.capture.RequestHeaders[key], value...)
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.capture.Timings.DNSMillis = millisSince(r.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if err == nil {
+				r.capture.Timings.ConnectMillis = millisSince(r.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if err == nil {
+				r.capture.Timings.TLSMillis = millisSince(r.tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.capture.Timings.TTFBMillis = millisSince(r.start)
+		},
+	})
+}
+
+func (r *attemptRecorder) finish(resp *http.Response) *AttemptCapture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	capture := r.capture
+	capture.RequestHeaders = redactHeaders(r.capture.RequestHeaders)
+	if resp != nil {
+		capture.ResponseHeaders = redactHeaders(resp.Header)
+	}
+	return &capture
+}
+
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range redactedHeaders {
+		if _, ok := redacted[name]; ok {
+			redacted[name] = []string{redactedHeaderValue}
+		}
+	}
+	return redacted
+}
+
+func millisSince(start time.Time) *int64 {
+	if start.IsZero() {
+		return nil
+	}
+	millis := time.Since(start).Milliseconds()
+	return &millis
+}
@@ -19,35 +19,110 @@ type Config struct {
 
 	ID        string     `json:"id" bun:",pk"`
 	Active    bool       `json:"active"`
-	Name      string     `json:"name" bun:"name,nullzero"`
 	CreatedAt time.Time  `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
 	UpdatedAt time.Time  `json:"updatedAt" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
 	DeletedAt *time.Time `json:"-" bun:"deleted_at"`
+	// DrainingSince is set while a config deleted in drain mode finishes its
+	// pending deliveries. It no longer receives new events.
+	DrainingSince *time.Time `json:"drainingSince,omitempty" bun:"draining_since"`
+	// PausedAt is set while deliveries to the config are held back. Events keep
+	// being enqueued and are delivered on resume, or from ResumeAt if set.
+	PausedAt *time.Time `json:"pausedAt,omitempty" bun:"paused_at"`
+	ResumeAt *time.Time `json:"resumeAt,omitempty" bun:"resume_at"`
+	// MaintenanceStartsAt and MaintenanceEndsAt bound the current or next
+	// maintenance window. They are refreshed by the worker once the window ends.
+	MaintenanceStartsAt *time.Time `json:"maintenanceStartsAt,omitempty" bun:"maintenance_starts_at"`
+	MaintenanceEndsAt   *time.Time `json:"maintenanceEndsAt,omitempty" bun:"maintenance_ends_at"`
+	// DisabledReason is set when the worker's failure policy deactivated or
+	// paused the config, and cleared when it is activated or resumed.
+	DisabledReason string `json:"disabledReason,omitempty" bun:"disabled_reason,nullzero"`
+	// ConsecutiveFailures counts the deliveries that failed since the last
+	// successful one.
+	ConsecutiveFailures int `json:"-" bun:"consecutive_failures,notnull,default:0"`
+}
+
+// ScheduleMaintenance sets the bounds of the current or next maintenance
+// window as of now.
+func (c *Config) ScheduleMaintenance(now time.Time) {
+	c.MaintenanceStartsAt, c.MaintenanceEndsAt = nil, nil
+	if start, end, ok := c.NextMaintenanceWindow(now); ok {
+		c.MaintenanceStartsAt, c.MaintenanceEndsAt = &start, &end
+	}
+}
+
+type ConfigPause struct {
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
 }
 
 type ConfigUser struct {
+	Name       string   `json:"name" bun:"name,nullzero"`
 	Endpoint   string   `json:"endpoint"`
 	Secret     string   `json:"secret"`
 	EventTypes []string `json:"eventTypes" bun:"event_types,array"`
+	// MaintenanceWindows are recurring periods during which deliveries to the
+	// endpoint are held back.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty" bun:"maintenance_windows,type:jsonb,nullzero"`
+	// CaptureAttempts records the full exchange of each delivery attempt, see
+	// AttemptCapture.
+	CaptureAttempts bool `json:"captureAttempts,omitempty" bun:"capture_attempts,notnull,default:false"`
+	// Priority is the claiming lane of the config's deliveries, normal when
+	// empty. EventTypePriorities overrides it for some event types.
+	Priority            string            `json:"priority,omitempty" bun:"priority,nullzero"`
+	EventTypePriorities map[string]string `json:"eventTypePriorities,omitempty" bun:"event_type_priorities,type:jsonb,nullzero"`
+	// Monitor labels the config's deliveries with its ID in metrics, when the
+	// worker runs with monitored config metrics.
+	Monitor bool `json:"monitor,omitempty" bun:"monitor,notnull,default:false"`
+	// OwnerContactURL receives a signed notification when the failure policy
+	// disables the config.
+	OwnerContactURL string `json:"ownerContactUrl,omitempty" bun:"owner_contact_url,nullzero"`
 }
 
 func NewConfig(cfgUser ConfigUser) Config {
-	return Config{
+	cfg := Config{
 		ConfigUser: cfgUser,
 		ID:         uuid.NewString(),
 		Active:     true,
 		CreatedAt:  time.Now().UTC(),
 		UpdatedAt:  time.Now().UTC(),
 	}
+	cfg.ScheduleMaintenance(cfg.CreatedAt)
+	return cfg
+}
+
+// Config deletion modes.
+const (
+	// DeleteModeCancel deletes the config and cancels its pending deliveries.
+	DeleteModeCancel = "cancel"
+	// DeleteModeDrain stops enqueuing events for the config and deletes it once
+	// its pending deliveries are finished.
+	DeleteModeDrain = "drain"
+	// DeleteModePurge removes the config and all its deliveries from the database.
+	DeleteModePurge = "purge"
+)
+
+func IsValidDeleteMode(mode string) bool {
+	switch mode {
+	case DeleteModeCancel, DeleteModeDrain, DeleteModePurge:
+		return true
+	default:
+		return false
+	}
 }
 
 var (
 	ErrInvalidEndpoint   = errors.New("endpoint should be a valid url")
 	ErrInvalidEventTypes = errors.New("eventTypes should be filled")
 	ErrInvalidSecret     = errors.New("decoded secret should be of size 24")
+	ErrInvalidName       = errors.New("name should not exceed 255 characters")
+	ErrInvalidPriority   = errors.New("priority should be high, normal or low")
+	ErrInvalidOwnerURL   = errors.New("ownerContactUrl should be a valid url")
 )
 
 func (c *ConfigUser) Validate() error {
+	if len(c.Name) > 255 {
+		return ErrInvalidName
+	}
+
 	if u, err := url.Parse(c.Endpoint); err != nil || len(u.String()) == 0 {
 		return ErrInvalidEndpoint
 	}
@@ -73,5 +148,17 @@ func (c *ConfigUser) Validate() error {
 		c.EventTypes[i] = strings.ToLower(t)
 	}
 
-	return nil
+	for _, w := range c.MaintenanceWindows {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.OwnerContactURL != "" {
+		if _, err := url.Parse(c.OwnerContactURL); err != nil {
+			return ErrInvalidOwnerURL
+		}
+	}
+
+	return c.validatePriorities()
 }
@@ -39,4 +39,11 @@ func TestConfig_Validate(t *testing.T) {
 		EventTypes: []string{"TYPE1", "TYPE2"},
 	}
 	assert.Error(t, cfg.Validate())
+
+	cfg = ConfigUser{
+		Endpoint:        "https://example.com",
+		EventTypes:      []string{"TYPE1"},
+		OwnerContactURL: " http://invalid",
+	}
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidOwnerURL)
 }
@@ -23,48 +23,146 @@ const (
 type Delivery struct {
 	bun.BaseModel `bun:"table:deliveries"`
 
-	ID               string     `json:"id" bun:",pk"`
-	EventID          string     `json:"eventID" bun:"event_id,notnull"`
-	IdempotencyKey   string     `json:"idempotencyKey,omitempty" bun:"idempotency_key"`
-	ConfigID         string     `json:"configID" bun:"config_id,notnull"`
-	EventType        string     `json:"eventType" bun:"event_type,notnull"`
-	Payload          string     `json:"payload,omitempty" bun:"payload,notnull"`
-	Status           string     `json:"status" bun:"status,notnull"`
-	AttemptCount     int        `json:"attemptCount" bun:"attempt_count,notnull"`
-	ReplayGeneration int        `json:"replayGeneration" bun:"replay_generation,notnull"`
-	CycleStartedAt   *time.Time `json:"cycleStartedAt,omitempty" bun:"cycle_started_at"`
-	NextAttemptAt    *time.Time `json:"nextAttemptAt,omitempty" bun:"next_attempt_at"`
-	ClaimedAt        *time.Time `json:"claimedAt,omitempty" bun:"claimed_at"`
-	LastAttemptAt    *time.Time `json:"lastAttemptAt,omitempty" bun:"last_attempt_at"`
-	LastStatusCode   *int       `json:"lastStatusCode,omitempty" bun:"last_status_code"`
-	LastError        string     `json:"lastError,omitempty" bun:"last_error"`
-	CreatedAt        time.Time  `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt        time.Time  `json:"updatedAt" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	ID               string `json:"id" bun:",pk"`
+	EventID          string `json:"eventID" bun:"event_id,notnull"`
+	IdempotencyKey   string `json:"idempotencyKey,omitempty" bun:"idempotency_key"`
+	ConfigID         string `json:"configID" bun:"config_id,notnull"`
+	EventType        string `json:"eventType" bun:"event_type,notnull"`
+	Payload          string `json:"payload,omitempty" bun:"payload,notnull"`
+	Status           string `json:"status" bun:"status,notnull"`
+	Priority         string `json:"priority" bun:"priority,nullzero,notnull,default:'normal'"`
+	AttemptCount     int    `json:"attemptCount" bun:"attempt_count,notnull"`
+	ReplayGeneration int    `json:"replayGeneration" bun:"replay_generation,notnull"`
+	// DeliverAt is set when the event asked for a delayed first attempt.
+	DeliverAt      *time.Time `json:"deliverAt,omitempty" bun:"deliver_at"`
+	CycleStartedAt *time.Time `json:"cycleStartedAt,omitempty" bun:"cycle_started_at"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty" bun:"next_attempt_at"`
+	ClaimedAt      *time.Time `json:"claimedAt,omitempty" bun:"claimed_at"`
+	// ClaimedBy and LeaseExpiresAt identify the worker holding the claim and
+	// how long it holds it without a heartbeat.
+	ClaimedBy      string     `json:"claimedBy,omitempty" bun:"claimed_by,nullzero"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty" bun:"lease_expires_at"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty" bun:"last_attempt_at"`
+	LastStatusCode *int       `json:"lastStatusCode,omitempty" bun:"last_status_code"`
+	LastError      string     `json:"lastError,omitempty" bun:"last_error"`
+	// ReplayedFrom is the delivery this one was copied from by a replay to
+	// another config.
+	ReplayedFrom string `json:"replayedFrom,omitempty" bun:"replayed_from,nullzero"`
+	// CancelledBy and CancellationReason are set when an operator cancels the
+	// delivery through the API.
+	CancelledBy        string    `json:"cancelledBy,omitempty" bun:"cancelled_by,nullzero"`
+	CancellationReason string    `json:"cancellationReason,omitempty" bun:"cancellation_reason,nullzero"`
+	CreatedAt          time.Time `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt          time.Time `json:"updatedAt" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// DueAt is when the delivery was first due: its creation, or its requested
+// deliverAt when later.
+func (d Delivery) DueAt() time.Time {
+	if d.DeliverAt != nil && d.DeliverAt.After(d.CreatedAt) {
+		return *d.DeliverAt
+	}
+	return d.CreatedAt
+}
+
+// DefaultClaimLeaseDuration is how long a claim outlives its last heartbeat
+// when the lease does not say otherwise.
+const DefaultClaimLeaseDuration = time.Minute
+
+// ClaimLease is what a dispatcher worker holds its claims with: its identity,
+// and how long a claim lasts past the last heartbeat.
+type ClaimLease struct {
+	WorkerID string
+	Duration time.Duration
+}
+
+// DeliveryCompletion is what recording an attempt left behind: the status of
+// the delivery, and how many deliveries of its config failed in a row since.
+type DeliveryCompletion struct {
+	Status              string
+	ConsecutiveFailures int
 }
 
 type DeliveryAttempt struct {
 	bun.BaseModel `bun:"table:delivery_attempts"`
 
-	ID               string    `json:"id" bun:",pk"`
-	DeliveryID       string    `json:"deliveryID" bun:"delivery_id,notnull"`
-	AttemptNumber    int       `json:"attemptNumber" bun:"attempt_number,notnull"`
-	ReplayGeneration int       `json:"replayGeneration" bun:"replay_generation,notnull"`
-	Endpoint         string    `json:"endpoint" bun:"endpoint,notnull"`
-	Outcome          string    `json:"outcome" bun:"outcome,notnull"`
-	StatusCode       int       `json:"statusCode" bun:"status_code,notnull"`
-	Error            string    `json:"error,omitempty" bun:"error"`
-	DurationMillis   *int64    `json:"durationMillis,omitempty" bun:"duration_millis"`
-	ResponseExcerpt  string    `json:"responseExcerpt,omitempty" bun:"response_excerpt"`
-	CreatedAt        time.Time `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	ID               string `json:"id" bun:",pk"`
+	DeliveryID       string `json:"deliveryID" bun:"delivery_id,notnull"`
+	AttemptNumber    int    `json:"attemptNumber" bun:"attempt_number,notnull"`
+	ReplayGeneration int    `json:"replayGeneration" bun:"replay_generation,notnull"`
+	Endpoint         string `json:"endpoint" bun:"endpoint,notnull"`
+	Outcome          string `json:"outcome" bun:"outcome,notnull"`
+	StatusCode       int    `json:"statusCode" bun:"status_code,notnull"`
+	Error            string `json:"error,omitempty" bun:"error"`
+	DurationMillis   *int64 `json:"durationMillis,omitempty" bun:"duration_millis"`
+	ResponseExcerpt  string `json:"responseExcerpt,omitempty" bun:"response_excerpt"`
+	// Capture is only recorded when the config has CaptureAttempts enabled.
+	Capture *AttemptCapture `json:"capture,omitempty" bun:"capture,type:jsonb"`
+	// WorkerID is the worker that made the attempt.
+	WorkerID  string    `json:"workerId,omitempty" bun:"worker_id,nullzero"`
+	CreatedAt time.Time `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+const (
+	DeliveryEventTypeDelivery = "delivery"
+	DeliveryEventTypeAttempt  = "attempt"
+)
+
+// DeliveryEvent is one entry of the delivery activity feed: either a status
+// transition of a delivery or a new attempt. Rows are written by the store in
+// the transaction making the change.
+type DeliveryEvent struct {
+	bun.BaseModel `bun:"table:delivery_events"`
+
+	ID         int64     `json:"id" bun:",pk,autoincrement"`
+	TxID       uint64    `json:"-" bun:"xid,scanonly"`
+	Type       string    `json:"type" bun:"type,notnull"`
+	DeliveryID string    `json:"deliveryID" bun:"delivery_id,notnull"`
+	ConfigID   string    `json:"configID" bun:"config_id,notnull"`
+	Status     string    `json:"status,omitempty" bun:"status,nullzero"`
+	AttemptID  string    `json:"attemptID,omitempty" bun:"attempt_id,nullzero"`
+	Outcome    string    `json:"outcome,omitempty" bun:"outcome,nullzero"`
+	StatusCode *int      `json:"statusCode,omitempty" bun:"status_code"`
+	CreatedAt  time.Time `json:"createdAt" bun:"created_at,nullzero,notnull,default:clock_timestamp()"`
+}
+
+func (e DeliveryEvent) Position() DeliveryEventPosition {
+	return DeliveryEventPosition{TxID: e.TxID, ID: e.ID}
+}
+
+// DeliveryEventPosition locates an event in the feed, which is ordered by the
+// ID of the transaction that recorded the event, then by event ID. Event IDs
+// alone are allocated before commit, so they are not a safe resume point.
+type DeliveryEventPosition struct {
+	TxID uint64
+	ID   int64
+}
+
+// DeliveryEventFilter selects delivery events after a given position. Status
+// only matches delivery transitions: attempt events are excluded when set.
+type DeliveryEventFilter struct {
+	After    DeliveryEventPosition
+	ConfigID string
+	Status   string
+	Limit    int
 }
 
 type DeliveryFilter struct {
-	ConfigID      string
-	Status        string
-	CreatedAfter  time.Time
-	CreatedBefore time.Time
-	After         *DeliveryCursor
-	PageSize      int
+	ConfigID string
+	Status   string
+	// Scheduled only keeps pending deliveries whose DeliverAt is still ahead.
+	Scheduled      bool
+	EventID        string
+	IdempotencyKey string
+	EventType      string
+	LastStatusCode *int
+	// PayloadContains is a JSON object that the stored event, as sent to the
+	// endpoint, must contain (PostgreSQL jsonb @> semantics).
+	PayloadContains string
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	After           *DeliveryCursor
+	PageSize        int
 }
 
 type DeliveryCursor struct {
@@ -73,11 +171,12 @@ type DeliveryCursor struct {
 }
 
 type ReplayDeliveryCursor struct {
-	Position      DeliveryCursor `json:"position"`
-	CreatedAtFrom time.Time      `json:"createdAtFrom"`
-	CreatedAtTo   time.Time      `json:"createdAtTo"`
-	Statuses      []string       `json:"statuses"`
-	ConfigIDs     []string       `json:"configIds,omitempty"`
+	Position       DeliveryCursor `json:"position"`
+	CreatedAtFrom  time.Time      `json:"createdAtFrom"`
+	CreatedAtTo    time.Time      `json:"createdAtTo"`
+	Statuses       []string       `json:"statuses"`
+	ConfigIDs      []string       `json:"configIds,omitempty"`
+	TargetConfigID string         `json:"targetConfigId,omitempty"`
 }
 
 type DeliveryPage struct {
@@ -87,18 +186,78 @@ type DeliveryPage struct {
 }
 
 type ReplayDeliveriesRequest struct {
+	CreatedAtFrom time.Time `json:"createdAtFrom"`
+	CreatedAtTo   time.Time `json:"createdAtTo,omitempty"`
+	Statuses      []string  `json:"statuses,omitempty"`
+	ConfigIDs     []string  `json:"configIds,omitempty"`
+	// TargetConfigID replays the selected deliveries as new deliveries to
+	// another config instead of re-queuing them in place.
+	TargetConfigID string          `json:"targetConfigId,omitempty"`
+	Cursor         *DeliveryCursor `json:"-"`
+	CursorToken    string          `json:"cursor,omitempty"`
+	PageSize       int             `json:"pageSize,omitempty"`
+}
+
+type ReplayDeliveriesResult struct {
+	Replayed        int             `json:"replayed"`
+	Expedited       int             `json:"expedited"`
+	Skipped         int             `json:"skipped"`
+	HasMore         bool            `json:"hasMore"`
+	NextCursor      *DeliveryCursor `json:"-"`
+	NextCursorToken string          `json:"nextCursor,omitempty"`
+	CreatedAtTo     time.Time       `json:"createdAtTo"`
+}
+
+type ReplayDeliveryRequest struct {
+	TargetConfigID string `json:"targetConfigId,omitempty"`
+}
+
+// RescheduleDeliveryRequest moves a pending delivery's next attempt, now when
+// NextAttemptAt is nil. ResetRetryCycle starts a new replay generation so the
+// attempts already made no longer count against the retry budget.
+type RescheduleDeliveryRequest struct {
+	NextAttemptAt   *time.Time `json:"nextAttemptAt,omitempty"`
+	ResetRetryCycle bool       `json:"resetRetryCycle,omitempty"`
+}
+
+// DeliveryCancellation records who cancelled deliveries and why. By is taken
+// from the caller's access token, never from the request body.
+type DeliveryCancellation struct {
+	By     string `json:"-"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type CancelDeliveriesRequest struct {
+	CreatedAtFrom time.Time `json:"createdAtFrom"`
+	CreatedAtTo   time.Time `json:"createdAtTo,omitempty"`
+	ConfigIDs     []string  `json:"configIds,omitempty"`
+	EventTypes    []string  `json:"eventTypes,omitempty"`
+	ScheduledOnly bool      `json:"scheduledOnly,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	PageSize      int       `json:"pageSize,omitempty"`
+}
+
+// CancelDeliveriesResult reports one page of a bulk cancellation. Cancelled
+// deliveries leave the filter, so the next page is obtained by sending the
+// same filters, with CreatedAtTo, under a new idempotency key.
+type CancelDeliveriesResult struct {
+	Cancelled   int       `json:"cancelled"`
+	HasMore     bool      `json:"hasMore"`
+	CreatedAtTo time.Time `json:"createdAtTo"`
+}
+
+// BackfillConfigRequest selects past events, by the creation time of their
+// first delivery, to enqueue for a config.
+type BackfillConfigRequest struct {
 	CreatedAtFrom time.Time       `json:"createdAtFrom"`
 	CreatedAtTo   time.Time       `json:"createdAtTo,omitempty"`
-	Statuses      []string        `json:"statuses,omitempty"`
-	ConfigIDs     []string        `json:"configIds,omitempty"`
 	Cursor        *DeliveryCursor `json:"-"`
 	CursorToken   string          `json:"cursor,omitempty"`
 	PageSize      int             `json:"pageSize,omitempty"`
 }
 
-type ReplayDeliveriesResult struct {
-	Replayed        int             `json:"replayed"`
-	Expedited       int             `json:"expedited"`
+type BackfillConfigResult struct {
+	Enqueued        int             `json:"enqueued"`
 	Skipped         int             `json:"skipped"`
 	HasMore         bool            `json:"hasMore"`
 	NextCursor      *DeliveryCursor `json:"-"`
@@ -106,6 +265,13 @@ type ReplayDeliveriesResult struct {
 	CreatedAtTo     time.Time       `json:"createdAtTo"`
 }
 
+type BackfillCursor struct {
+	Position      DeliveryCursor `json:"position"`
+	ConfigID      string         `json:"configId"`
+	CreatedAtFrom time.Time      `json:"createdAtFrom"`
+	CreatedAtTo   time.Time      `json:"createdAtTo"`
+}
+
 type ReplayRequestRecord struct {
 	bun.BaseModel `bun:"table:replay_requests"`
 
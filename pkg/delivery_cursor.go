@@ -4,6 +4,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 func EncodeDeliveryCursor(cursor *DeliveryCursor) (string, error) {
@@ -55,3 +57,48 @@ func DecodeReplayDeliveryCursor(value string) (*ReplayDeliveryCursor, error) {
 	}
 	return &cursor, nil
 }
+
+func EncodeBackfillCursor(cursor BackfillCursor) (string, error) {
+	body, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+func DecodeBackfillCursor(value string) (*BackfillCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backfill cursor: %w", err)
+	}
+	cursor := BackfillCursor{}
+	if err := json.Unmarshal(body, &cursor); err != nil || cursor.Position.ID == "" || cursor.ConfigID == "" ||
+		cursor.Position.CreatedAt.IsZero() || cursor.CreatedAtFrom.IsZero() || cursor.CreatedAtTo.IsZero() {
+		return nil, fmt.Errorf("invalid backfill cursor")
+	}
+	return &cursor, nil
+}
+
+// EncodeDeliveryEventPosition formats position as a server-sent event ID.
+func EncodeDeliveryEventPosition(position DeliveryEventPosition) string {
+	return fmt.Sprintf("%d-%d", position.TxID, position.ID)
+}
+
+func DecodeDeliveryEventPosition(value string) (DeliveryEventPosition, error) {
+	txID, id, ok := strings.Cut(value, "-")
+	if !ok {
+		return DeliveryEventPosition{}, fmt.Errorf("invalid delivery event position")
+	}
+	position := DeliveryEventPosition{}
+	var err error
+	if position.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+		return DeliveryEventPosition{}, fmt.Errorf("invalid delivery event position: %w", err)
+	}
+	if position.ID, err = strconv.ParseInt(id, 10, 64); err != nil || position.ID < 0 {
+		return DeliveryEventPosition{}, fmt.Errorf("invalid delivery event position")
+	}
+	return position, nil
+}
@@ -30,3 +30,34 @@ func TestReplayDeliveryCursorRejectsListCursor(t *testing.T) {
 	_, err = webhooks.DecodeReplayDeliveryCursor(token)
 	require.Error(t, err)
 }
+
+func TestBackfillCursorRoundTripsAndRejectsReplayCursor(t *testing.T) {
+	from := time.Now().UTC().Add(-time.Hour).Truncate(time.Microsecond)
+	want := webhooks.BackfillCursor{
+		Position: webhooks.DeliveryCursor{CreatedAt: from.Add(time.Minute), ID: "delivery-id"},
+		ConfigID: "config-id", CreatedAtFrom: from, CreatedAtTo: from.Add(30 * time.Minute),
+	}
+	token, err := webhooks.EncodeBackfillCursor(want)
+	require.NoError(t, err)
+	got, err := webhooks.DecodeBackfillCursor(token)
+	require.NoError(t, err)
+	require.Equal(t, want, *got)
+
+	token, err = webhooks.EncodeReplayDeliveryCursor(webhooks.ReplayDeliveryCursor{
+		Position: want.Position, CreatedAtFrom: want.CreatedAtFrom, CreatedAtTo: want.CreatedAtTo,
+	})
+	require.NoError(t, err)
+	_, err = webhooks.DecodeBackfillCursor(token)
+	require.Error(t, err)
+}
+
+func TestDeliveryEventPositionRoundTripsAndRejectsEventIDs(t *testing.T) {
+	want := webhooks.DeliveryEventPosition{TxID: 1<<32 + 42, ID: 7}
+	got, err := webhooks.DecodeDeliveryEventPosition(webhooks.EncodeDeliveryEventPosition(want))
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	for _, value := range []string{"7", "-7", "42-", "x-7", "42--7"} {
+		_, err := webhooks.DecodeDeliveryEventPosition(value)
+		require.Error(t, err, value)
+	}
+}
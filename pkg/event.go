@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxEventIDLength = 255
+
+var (
+	ErrInvalidEventType = errors.New("type should be filled")
+	ErrInvalidEventID   = errors.New("id should not exceed 255 characters")
+)
+
+// NormalizeEventType returns the type configs subscribe to: lowercase, and
+// prefixed with the emitting app when there is one.
+func NormalizeEventType(app, eventType string) string {
+	app = strings.ToLower(app)
+	eventType = strings.ToLower(eventType)
+	if app == "" {
+		return eventType
+	}
+	return strings.Join([]string{app, eventType}, ".")
+}
+
+// PublishEventRequest is an event submitted over HTTP rather than through the
+// broker. Submitting the same ID twice enqueues it once.
+type PublishEventRequest struct {
+	ID             string          `json:"id,omitempty"`
+	App            string          `json:"app,omitempty"`
+	Version        string          `json:"version,omitempty"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	DeliverAt      *time.Time      `json:"deliverAt,omitempty"`
+}
+
+func (r PublishEventRequest) Validate() error {
+	if r.Type == "" {
+		return ErrInvalidEventType
+	}
+	if len(r.ID) > maxEventIDLength {
+		return ErrInvalidEventID
+	}
+	return nil
+}
+
+// EnqueuedEvent lists the deliveries an event produced, one per matching
+// config. Duplicate marks deliveries that already existed for the event ID.
+type EnqueuedEvent struct {
+	EventID   string       `json:"eventId"`
+	EventType string       `json:"eventType"`
+	Matches   []EventMatch `json:"matches"`
+}
+
+type EventMatch struct {
+	ConfigID   string `json:"configId"`
+	DeliveryID string `json:"deliveryId"`
+	Duplicate  bool   `json:"duplicate"`
+}
@@ -0,0 +1,19 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEventType(t *testing.T) {
+	require.Equal(t, "ledger.committed_transactions", NormalizeEventType("Ledger", "COMMITTED_TRANSACTIONS"))
+	require.Equal(t, "test.event", NormalizeEventType("", "Test.Event"))
+}
+
+func TestPublishEventRequestValidate(t *testing.T) {
+	require.NoError(t, PublishEventRequest{Type: "test.event"}.Validate())
+	require.ErrorIs(t, PublishEventRequest{}.Validate(), ErrInvalidEventType)
+	require.ErrorIs(t, PublishEventRequest{Type: "test.event", ID: strings.Repeat("a", 256)}.Validate(), ErrInvalidEventID)
+}
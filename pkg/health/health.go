@@ -0,0 +1,104 @@
+// Package health serves the liveness and readiness probes. Modules register
+// the dependencies a process needs with ProvideCheck, and the readiness probe
+// reports each one.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	checkTimeout = 2 * time.Second
+	checksGroup  = `group:"readinessChecks"`
+)
+
+// Check probes one dependency and returns nil when it is usable.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Checks collects the checks registered with ProvideCheck.
+type Checks struct {
+	fx.In
+
+	Checks []Check `group:"readinessChecks"`
+}
+
+// ProvideCheck registers the Check returned by constructor.
+func ProvideCheck(constructor any) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(checksGroup)))
+}
+
+type CheckStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckStatus `json:"checks,omitempty"`
+}
+
+// Run runs the checks concurrently, each bounded by a short timeout. The
+// report is down as soon as one check is.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckStatus, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			status := CheckStatus{Status: StatusUp}
+			if err := check.Check(ctx); err != nil {
+				status = CheckStatus{Status: StatusDown, Error: err.Error()}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = status
+			if status.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// LiveHandle answers as long as the process serves HTTP. It checks no
+// dependency, so that an outage of one does not get every pod restarted.
+func LiveHandle(w http.ResponseWriter, _ *http.Request) {
+	write(w, http.StatusOK, Report{Status: StatusUp})
+}
+
+// ReadyHandler reports every check, with a 503 when one of them is down.
+func ReadyHandler(checks Checks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), checks.Checks)
+		status := http.StatusOK
+		if report.Status == StatusDown {
+			status = http.StatusServiceUnavailable
+		}
+		write(w, status, report)
+	}
+}
+
+func write(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyHandlerReportsEachCheck(t *testing.T) {
+	up := Check{Name: "postgres", Check: func(context.Context) error { return nil }}
+	down := Check{Name: "broker", Check: func(context.Context) error { return errors.New("not subscribed") }}
+
+	rec := httptest.NewRecorder()
+	ReadyHandler(Checks{Checks: []Check{up, down}})(rec, httptest.NewRequest(http.MethodGet, "/_ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	report := Report{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Equal(t, Report{Status: StatusDown, Checks: map[string]CheckStatus{
+		"postgres": {Status: StatusUp},
+		"broker":   {Status: StatusDown, Error: "not subscribed"},
+	}}, report)
+
+	rec = httptest.NewRecorder()
+	ReadyHandler(Checks{Checks: []Check{up}})(rec, httptest.NewRequest(http.MethodGet, "/_ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRunStopsChecksWithTheRequest(t *testing.T) {
+	slow := Check{Name: "postgres", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := Run(ctx, []Check{slow})
+	require.Equal(t, StatusDown, report.Status)
+	require.Equal(t, context.Canceled.Error(), report.Checks["postgres"].Error)
+}
+
+func TestLiveHandleChecksNothing(t *testing.T) {
+	rec := httptest.NewRecorder()
+	LiveHandle(rec, httptest.NewRequest(http.MethodGet, "/_live", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"up"}`, rec.Body.String())
+}
@@ -0,0 +1,101 @@
+package webhooks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// maxMaintenanceWindowMerges bounds how many overlapping windows are chained
+// together when computing the end of a maintenance period.
+const maxMaintenanceWindowMerges = 16
+
+var ErrInvalidMaintenanceWindow = errors.New("invalid maintenance window")
+
+// MaintenanceWindow is a recurring period during which a config's endpoint is
+// known to be unavailable. Schedule is a standard 5-field cron expression
+// giving the window start in Timezone (UTC when empty).
+type MaintenanceWindow struct {
+	Schedule string `json:"schedule"`
+	Duration string `json:"duration"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (w MaintenanceWindow) Validate() error {
+	if _, _, _, err := w.parse(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMaintenanceWindow, err)
+	}
+	return nil
+}
+
+func (w MaintenanceWindow) parse() (cron.Schedule, time.Duration, *time.Location, error) {
+	schedule, err := cron.ParseStandard(w.Schedule)
+	if err != nil {
+		return nil, 0, nil, errors.Wrap(err, "parsing schedule")
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return nil, 0, nil, errors.Wrap(err, "parsing duration")
+	}
+	if duration <= 0 || duration > 7*24*time.Hour {
+		return nil, 0, nil, errors.New("duration should be between 0 and 168h")
+	}
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil, 0, nil, errors.Wrap(err, "loading timezone")
+	}
+	return schedule, duration, location, nil
+}
+
+// window returns the occurrence containing t, or the next one.
+func (w MaintenanceWindow) window(t time.Time) (time.Time, time.Time, bool) {
+	schedule, duration, location, err := w.parse()
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	start := schedule.Next(t.In(location).Add(-duration))
+	if start.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	return start.UTC(), start.Add(duration).UTC(), true
+}
+
+// NextMaintenanceWindow returns the bounds of the maintenance period containing
+// t, or of the next one. Overlapping and adjacent windows are merged.
+func (c ConfigUser) NextMaintenanceWindow(t time.Time) (time.Time, time.Time, bool) {
+	var start, end time.Time
+	for _, w := range c.MaintenanceWindows {
+		s, e, ok := w.window(t)
+		if ok && (start.IsZero() || s.Before(start) || (s.Equal(start) && e.After(end))) {
+			start, end = s, e
+		}
+	}
+	if start.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	for range maxMaintenanceWindowMerges {
+		extended := end
+		for _, w := range c.MaintenanceWindows {
+			if s, e, ok := w.window(end); ok && !s.After(end) && e.After(extended) {
+				extended = e
+			}
+		}
+		if extended.Equal(end) {
+			break
+		}
+		end = extended
+	}
+	return start, end, true
+}
+
+// MaintenanceEnd reports whether t falls inside a maintenance window and, if
+// so, when that window ends.
+func (c ConfigUser) MaintenanceEnd(t time.Time) (time.Time, bool) {
+	start, end, ok := c.NextMaintenanceWindow(t)
+	if !ok || start.After(t) {
+		return time.Time{}, false
+	}
+	return end, true
+}
@@ -0,0 +1,49 @@
+package webhooks_test
+
+import (
+	"testing"
+	"time"
+
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindowInTimezone(t *testing.T) {
+	cfg := webhooks.ConfigUser{MaintenanceWindows: []webhooks.MaintenanceWindow{{
+		Schedule: "0 2 * * *", Duration: "1h", Timezone: "Europe/Paris",
+	}}}
+	// 02:30 in Paris during winter time.
+	inside := time.Date(2026, time.January, 10, 1, 30, 0, 0, time.UTC)
+	end, ok := cfg.MaintenanceEnd(inside)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2026, time.January, 10, 2, 0, 0, 0, time.UTC), end)
+
+	_, ok = cfg.MaintenanceEnd(end)
+	require.False(t, ok, "windows end exclusively")
+	start, next, ok := cfg.NextMaintenanceWindow(end)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2026, time.January, 11, 1, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2026, time.January, 11, 2, 0, 0, 0, time.UTC), next)
+}
+
+func TestMaintenanceWindowsMergeWhenOverlapping(t *testing.T) {
+	cfg := webhooks.ConfigUser{MaintenanceWindows: []webhooks.MaintenanceWindow{
+		{Schedule: "0 2 * * *", Duration: "1h"},
+		{Schedule: "30 2 * * *", Duration: "1h"},
+		{Schedule: "30 3 * * *", Duration: "30m"},
+	}}
+	end, ok := cfg.MaintenanceEnd(time.Date(2026, time.January, 10, 2, 10, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2026, time.January, 10, 4, 0, 0, 0, time.UTC), end)
+}
+
+func TestMaintenanceWindowValidation(t *testing.T) {
+	for _, w := range []webhooks.MaintenanceWindow{
+		{Schedule: "not a cron", Duration: "1h"},
+		{Schedule: "0 2 * * *", Duration: "-1h"},
+		{Schedule: "0 2 * * *", Duration: "1h", Timezone: "Mars/Olympus"},
+	} {
+		require.ErrorIs(t, w.Validate(), webhooks.ErrInvalidMaintenanceWindow)
+	}
+	require.NoError(t, webhooks.MaintenanceWindow{Schedule: "0 2 * * 1-5", Duration: "90m", Timezone: "UTC"}.Validate())
+}
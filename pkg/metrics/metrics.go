@@ -8,6 +8,7 @@ package metrics
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -24,6 +25,15 @@ var (
 	replayCounter     metric.Int64Counter
 	transitionCounter metric.Int64Counter
 	recoveredCounter  metric.Int64Counter
+	lagHistogram      metric.Float64Histogram
+	firstAttemptDelay metric.Float64Histogram
+
+	configCounter  metric.Int64Counter
+	configDuration metric.Float64Histogram
+	configRefused  metric.Int64Counter
+	// labelledConfigs is the set of configs recorded by the per-config
+	// instruments. Nil disables them.
+	labelledConfigs atomic.Pointer[map[string]struct{}]
 )
 
 func instruments() (metric.Int64Counter, metric.Float64Histogram) {
@@ -50,6 +60,29 @@ func instruments() (metric.Int64Counter, metric.Float64Histogram) {
 			"webhooks_delivery_claims_recovered_total",
 			metric.WithDescription("Total stale durable delivery claims recovered after worker interruption"),
 		)
+		lagHistogram, _ = meter.Float64Histogram(
+			"webhooks_delivery_lag_seconds",
+			metric.WithDescription("Time from an event being due to its successful delivery"),
+			metric.WithUnit("s"),
+		)
+		firstAttemptDelay, _ = meter.Float64Histogram(
+			"webhooks_delivery_first_attempt_delay_seconds",
+			metric.WithDescription("Time from an event being due to the first delivery attempt"),
+			metric.WithUnit("s"),
+		)
+		configCounter, _ = meter.Int64Counter(
+			"webhooks_config_delivery_attempts_total",
+			metric.WithDescription("Total webhook delivery attempts of the labelled configs, by config and outcome status"),
+		)
+		configDuration, _ = meter.Float64Histogram(
+			"webhooks_config_delivery_duration_seconds",
+			metric.WithDescription("Duration of the outbound webhook HTTP call of the labelled configs"),
+			metric.WithUnit("s"),
+		)
+		configRefused, _ = meter.Int64Counter(
+			"webhooks_config_metrics_refused_total",
+			metric.WithDescription("Total configs selected for per-config metrics but left unlabelled, as the cap of configs ever labelled was reached"),
+		)
 	})
 	return deliveryCounter, deliveryDuration
 }
@@ -75,6 +108,20 @@ func RecordRecoveredClaims(ctx context.Context, count int64) {
 	recoveredCounter.Add(ctx, count)
 }
 
+// RecordDeliveryLag records the end-to-end latency of a successful delivery,
+// by priority lane.
+func RecordDeliveryLag(ctx context.Context, priority string, lag time.Duration) {
+	instruments()
+	lagHistogram.Record(ctx, lag.Seconds(), metric.WithAttributes(attribute.String("priority", priority)))
+}
+
+// RecordFirstAttemptDelay records how long a delivery waited for its first
+// attempt, by priority lane.
+func RecordFirstAttemptDelay(ctx context.Context, priority string, delay time.Duration) {
+	instruments()
+	firstAttemptDelay.Record(ctx, delay.Seconds(), metric.WithAttributes(attribute.String("priority", priority)))
+}
+
 // RecordDelivery records the outcome of a single delivery attempt.
 //
 // Attributes are deliberately low-cardinality (outcome status + HTTP status
@@ -91,6 +138,56 @@ func RecordDelivery(ctx context.Context, status string, statusCode int, elapsed
 	histogram.Record(ctx, elapsed.Seconds(), attrs)
 }
 
+// SetLabelledConfigs replaces the configs recorded by the per-config
+// instruments. Only a bounded set of configs is ever labelled, for the reason
+// RecordDelivery leaves endpoints out.
+func SetLabelledConfigs(ids []string) {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	labelledConfigs.Store(&set)
+}
+
+// RecordRefusedLabelledConfigs counts the configs selected for the per-config
+// instruments but refused past the cap of configs ever labelled.
+func RecordRefusedLabelledConfigs(ctx context.Context, count int) {
+	if count == 0 {
+		return
+	}
+	instruments()
+	configRefused.Add(ctx, int64(count))
+}
+
+// LabelledConfigs returns the configs recorded by the per-config instruments.
+func LabelledConfigs() []string {
+	set := labelledConfigs.Load()
+	if set == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(*set))
+	for id := range *set {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// RecordConfigDelivery records a delivery attempt under its config ID when the
+// config is labelled, and does nothing otherwise.
+func RecordConfigDelivery(ctx context.Context, configID, status string, elapsed time.Duration) {
+	set := labelledConfigs.Load()
+	if set == nil {
+		return
+	}
+	if _, ok := (*set)[configID]; !ok {
+		return
+	}
+	instruments()
+	configAttr := attribute.String("config_id", configID)
+	configCounter.Add(ctx, 1, metric.WithAttributes(configAttr, attribute.String("status", status)))
+	configDuration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(configAttr))
+}
+
 // statusClass buckets an HTTP status code into a low-cardinality class. A code
 // of 0 means the request never got a response (transport/timeout failure).
 func statusClass(statusCode int) string {
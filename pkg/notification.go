@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Failure policy actions, applied to a config failing persistently.
+const (
+	DisableActionDeactivate = "deactivate"
+	DisableActionPause      = "pause"
+)
+
+func IsValidDisableAction(action string) bool {
+	switch action {
+	case DisableActionDeactivate, DisableActionPause:
+		return true
+	default:
+		return false
+	}
+}
+
+// EventTypeConfigDisabled is published on the broker, and sent to the owner
+// contact URL of the config, when the failure policy disables a config.
+const EventTypeConfigDisabled = "CONFIG_DISABLED"
+
+// ConfigDisabled is the payload of EventTypeConfigDisabled.
+type ConfigDisabled struct {
+	ConfigID   string    `json:"configId"`
+	Name       string    `json:"name,omitempty"`
+	Endpoint   string    `json:"endpoint"`
+	Action     string    `json:"action"`
+	Reason     string    `json:"reason"`
+	DisabledAt time.Time `json:"disabledAt"`
+}
+
+// SendNotification posts payload to endpoint once, signed with secret like a
+// delivery. Any non-2xx response is an error.
+func SendNotification(ctx context.Context, httpClient *http.Client, endpoint, secret string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "http.NewRequestWithContext")
+	}
+	if err := signRequest(req, uuid.NewString(), time.Now().UTC().Unix(), secret, payload, false); err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending notification")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("notification endpoint answered %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Delivery priorities. Each one is a claiming lane of the dispatcher.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// Priorities lists the lanes from the most to the least urgent.
+var Priorities = []string{PriorityHigh, PriorityNormal, PriorityLow}
+
+// priorityWeights is the share of each claim batch reserved to a lane. Capacity
+// a lane does not use goes to the others, so a busy high lane slows the low
+// one down without ever starving it.
+var priorityWeights = map[string]int{
+	PriorityHigh:   6,
+	PriorityNormal: 3,
+	PriorityLow:    1,
+}
+
+func IsValidPriority(priority string) bool {
+	_, ok := priorityWeights[priority]
+	return ok
+}
+
+// PriorityQuotas splits a claim batch of limit deliveries between lanes, in
+// the order of Priorities. Every lane gets at least one slot when the batch is
+// large enough to hold one per lane.
+func PriorityQuotas(limit int) []int {
+	total := 0
+	for _, priority := range Priorities {
+		total += priorityWeights[priority]
+	}
+	quotas := make([]int, len(Priorities))
+	assigned := 0
+	for i, priority := range Priorities {
+		quotas[i] = limit * priorityWeights[priority] / total
+		if quotas[i] == 0 && limit >= len(Priorities) {
+			quotas[i] = 1
+		}
+		assigned += quotas[i]
+	}
+	for i := 0; assigned < limit; i = (i + 1) % len(quotas) {
+		quotas[i]++
+		assigned++
+	}
+	return quotas
+}
+
+// PriorityFor returns the lane of the deliveries of an event type: its
+// override in EventTypePriorities, else the config priority, else normal.
+func (c ConfigUser) PriorityFor(eventType string) string {
+	if priority, ok := c.EventTypePriorities[eventType]; ok {
+		return priority
+	}
+	if c.Priority != "" {
+		return c.Priority
+	}
+	return PriorityNormal
+}
+
+func (c *ConfigUser) validatePriorities() error {
+	if c.Priority != "" && !IsValidPriority(c.Priority) {
+		return fmt.Errorf("%w: %q", ErrInvalidPriority, c.Priority)
+	}
+	if len(c.EventTypePriorities) == 0 {
+		return nil
+	}
+	priorities := make(map[string]string, len(c.EventTypePriorities))
+	for eventType, priority := range c.EventTypePriorities {
+		if !IsValidPriority(priority) {
+			return fmt.Errorf("%w: %q", ErrInvalidPriority, priority)
+		}
+		priorities[strings.ToLower(eventType)] = priority
+	}
+	c.EventTypePriorities = priorities
+	return nil
+}
@@ -0,0 +1,37 @@
+package webhooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQuotas(t *testing.T) {
+	require.Equal(t, []int{30, 15, 5}, PriorityQuotas(50))
+	require.Equal(t, []int{6, 3, 1}, PriorityQuotas(10))
+	require.Equal(t, []int{1, 1, 1}, PriorityQuotas(3), "small batches keep one slot per lane")
+	require.Equal(t, []int{1, 0, 0}, PriorityQuotas(1))
+	for limit := 1; limit <= 100; limit++ {
+		sum := 0
+		for _, quota := range PriorityQuotas(limit) {
+			sum += quota
+		}
+		require.Equal(t, limit, sum)
+	}
+}
+
+func TestConfigUserPriorityFor(t *testing.T) {
+	cfg := ConfigUser{
+		Endpoint: "https://example.com", EventTypes: []string{"ledger.committed_transactions", "payments.saved_payment"},
+		EventTypePriorities: map[string]string{"Payments.Saved_Payment": PriorityHigh},
+	}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, PriorityHigh, cfg.PriorityFor("payments.saved_payment"))
+	require.Equal(t, PriorityNormal, cfg.PriorityFor("ledger.committed_transactions"))
+
+	cfg.Priority = PriorityLow
+	require.Equal(t, PriorityLow, cfg.PriorityFor("ledger.committed_transactions"))
+
+	cfg.Priority = "urgent"
+	require.ErrorIs(t, cfg.Validate(), ErrInvalidPriority)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/api"
+	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/metrics"
+	"github.com/formancehq/webhooks/pkg/server/apierrors"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *serverHandler) backfillOneConfigHandle(w http.ResponseWriter, r *http.Request) {
+	key, err := requireIdempotencyKey(r)
+	if err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	id := chi.URLParam(r, PathParamId)
+	request := webhooks.BackfillConfigRequest{}
+	if err := decodeJSONBody(r, &request, false); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if request.CreatedAtFrom.IsZero() {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("createdAtFrom is required"))
+		return
+	}
+	if request.PageSize == 0 {
+		request.PageSize = maxDeliveryPageSize
+	}
+	if request.PageSize < 1 || request.PageSize > maxDeliveryPageSize {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("pageSize must be between 1 and 1000"))
+		return
+	}
+	cursor, err := webhooks.DecodeBackfillCursor(request.CursorToken)
+	if err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if cursor != nil {
+		if request.CreatedAtTo.IsZero() {
+			request.CreatedAtTo = cursor.CreatedAtTo
+		}
+		if cursor.ConfigID != id ||
+			!request.CreatedAtFrom.Equal(cursor.CreatedAtFrom) ||
+			!request.CreatedAtTo.Equal(cursor.CreatedAtTo) {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("backfill cursor does not match request"))
+			return
+		}
+		request.Cursor = &cursor.Position
+	}
+	effectiveTo := request.CreatedAtTo
+	if effectiveTo.IsZero() {
+		effectiveTo = time.Now().UTC()
+	}
+	if effectiveTo.Before(request.CreatedAtFrom) || effectiveTo.Sub(request.CreatedAtFrom) > maxReplayWindow {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("backfill window must be positive and at most 90 days"))
+		return
+	}
+	result, applied, err := h.store.BackfillConfig(r.Context(), id, request, key)
+	if err != nil {
+		replayError(w, r, err)
+		return
+	}
+	if applied {
+		logging.FromContext(r.Context()).Infof("backfilled config %s: enqueued=%d skipped=%d", id, result.Enqueued, result.Skipped)
+		metrics.RecordReplay(r.Context(), "backfill", "enqueued", result.Enqueued)
+		metrics.RecordDeliveryTransition(r.Context(), webhooks.StatusDeliveryPending, "backfill", result.Enqueued)
+	}
+	if err := json.NewEncoder(w).Encode(api.BaseResponse[webhooks.BackfillConfigResult]{Data: &result}); err != nil {
+		apierrors.ResponseError(w, r, err)
+	}
+}
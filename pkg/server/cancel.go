@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/api"
+	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/metrics"
+	"github.com/formancehq/webhooks/pkg/server/apierrors"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/zitadel/oidc/v2/pkg/oidc"
+)
+
+const maxCancellationReasonLength = 1024
+
+type cancelDeliveryRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type callerKey struct{}
+
+// identifyCallers stores the subject of the caller's access token in the
+// request context. It must run after the auth middleware, which verified the
+// token: without auth the token is not verified, so no caller is recorded.
+func identifyCallers(authEnabled bool) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		if !authEnabled {
+			return handler
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if caller := tokenSubject(r); caller != "" {
+				r = r.WithContext(context.WithValue(r.Context(), callerKey{}, caller))
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func tokenSubject(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, oidc.PrefixBearer)
+	if !ok {
+		token, ok = strings.CutPrefix(header, strings.ToLower(oidc.PrefixBearer))
+	}
+	if !ok {
+		return ""
+	}
+	claims := oidc.TokenClaims{}
+	if _, err := oidc.ParseToken(token, &claims); err != nil {
+		return ""
+	}
+	if claims.Subject != "" {
+		return claims.Subject
+	}
+	return claims.ClientID
+}
+
+// requestActor returns the caller identified by identifyCallers, empty
+// without auth.
+func requestActor(r *http.Request) string {
+	caller, _ := r.Context().Value(callerKey{}).(string)
+	return caller
+}
+
+func cancelError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrDeliveryNotFound):
+		apierrors.ResponseError(w, r, apierrors.NewNotFoundError(err.Error()))
+	case errors.Is(err, storage.ErrDeliveryNotCancellable), errors.Is(err, storage.ErrIdempotencyConflict):
+		apierrors.ResponseError(w, r, apierrors.NewConflictError(err.Error()))
+	default:
+		apierrors.ResponseError(w, r, err)
+	}
+}
+
+func (h *serverHandler) cancelDeliveryHandle(w http.ResponseWriter, r *http.Request) {
+	key, err := requireIdempotencyKey(r)
+	if err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	request := cancelDeliveryRequest{}
+	if err := decodeJSONBody(r, &request, true); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if len(request.Reason) > maxCancellationReasonLength {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("reason must not exceed 1024 characters"))
+		return
+	}
+	cancellation := webhooks.DeliveryCancellation{By: requestActor(r), Reason: request.Reason}
+	delivery, applied, err := h.store.CancelOneDelivery(r.Context(), chi.URLParam(r, PathParamId), cancellation, key)
+	if err != nil {
+		cancelError(w, r, err)
+		return
+	}
+	if applied {
+		logging.FromContext(r.Context()).Infof("cancelled delivery %s by=%q reason=%q", delivery.ID, cancellation.By, cancellation.Reason)
+		metrics.RecordDeliveryTransition(r.Context(), webhooks.StatusDeliveryCancelled, "cancel", 1)
+	}
+	if err := json.NewEncoder(w).Encode(api.BaseResponse[webhooks.Delivery]{Data: &delivery}); err != nil {
+		apierrors.ResponseError(w, r, err)
+	}
+}
+
+func (h *serverHandler) cancelDeliveriesHandle(w http.ResponseWriter, r *http.Request) {
+	key, err := requireIdempotencyKey(r)
+	if err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	request := webhooks.CancelDeliveriesRequest{}
+	if err := decodeJSONBody(r, &request, false); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if request.CreatedAtFrom.IsZero() {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("createdAtFrom is required"))
+		return
+	}
+	if request.PageSize == 0 {
+		request.PageSize = maxDeliveryPageSize
+	}
+	if request.PageSize < 1 || request.PageSize > maxDeliveryPageSize {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("pageSize must be between 1 and 1000"))
+		return
+	}
+	if len(request.Reason) > maxCancellationReasonLength {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("reason must not exceed 1024 characters"))
+		return
+	}
+	for _, id := range request.ConfigIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("configIds must contain UUIDs"))
+			return
+		}
+	}
+	sort.Strings(request.ConfigIDs)
+	for i, eventType := range request.EventTypes {
+		if eventType == "" {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("eventTypes must not contain empty values"))
+			return
+		}
+		request.EventTypes[i] = strings.ToLower(eventType)
+	}
+	sort.Strings(request.EventTypes)
+	effectiveTo := request.CreatedAtTo
+	if effectiveTo.IsZero() {
+		effectiveTo = time.Now().UTC()
+	}
+	if effectiveTo.Before(request.CreatedAtFrom) || effectiveTo.Sub(request.CreatedAtFrom) > maxReplayWindow {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("cancellation window must be positive and at most 90 days"))
+		return
+	}
+	cancellation := webhooks.DeliveryCancellation{By: requestActor(r), Reason: request.Reason}
+	result, applied, err := h.store.CancelDeliveries(r.Context(), request, cancellation, key)
+	if err != nil {
+		cancelError(w, r, err)
+		return
+	}
+	if applied {
+		logging.FromContext(r.Context()).Infof("bulk cancel: cancelled=%d by=%q reason=%q", result.Cancelled, cancellation.By, cancellation.Reason)
+		metrics.RecordDeliveryTransition(r.Context(), webhooks.StatusDeliveryCancelled, "cancel", result.Cancelled)
+	}
+	if err := json.NewEncoder(w).Encode(api.BaseResponse[webhooks.CancelDeliveriesResult]{Data: &result}); err != nil {
+		apierrors.ResponseError(w, r, err)
+	}
+}
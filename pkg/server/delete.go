@@ -1,11 +1,13 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
 	"github.com/formancehq/webhooks/pkg/server/apierrors"
 	"github.com/formancehq/webhooks/pkg/storage"
 	"github.com/pkg/errors"
@@ -13,9 +15,19 @@ import (
 
 func (h *serverHandler) deleteOneConfigHandle(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, PathParamId)
-	err := h.store.DeleteOneConfig(r.Context(), id)
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = webhooks.DeleteModeCancel
+	}
+	if !webhooks.IsValidDeleteMode(mode) {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(
+			fmt.Sprintf("mode should be one of %s, %s or %s",
+				webhooks.DeleteModeCancel, webhooks.DeleteModeDrain, webhooks.DeleteModePurge)))
+		return
+	}
+	err := h.store.DeleteOneConfig(r.Context(), id, mode)
 	if err == nil {
-		logging.FromContext(r.Context()).Debugf("DELETE %s/%s", PathConfigs, id)
+		logging.FromContext(r.Context()).Debugf("DELETE %s/%s?mode=%s", PathConfigs, id, mode)
 	} else if errors.Is(err, storage.ErrConfigNotFound) {
 		logging.FromContext(r.Context()).Debugf("DELETE %s/%s: %s", PathConfigs, id, storage.ErrConfigNotFound)
 		apierrors.ResponseError(w, r, apierrors.NewNotFoundError(storage.ErrConfigNotFound.Error()))
@@ -7,6 +7,7 @@ import (
 	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/formancehq/go-libs/v2/api"
@@ -49,6 +50,22 @@ func parseOptionalTime(value string) (time.Time, error) {
 	return parsed.UTC(), nil
 }
 
+func parseOptionalStatusCode(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	statusCode, err := strconv.Atoi(value)
+	if err != nil || statusCode < 100 || statusCode > 599 {
+		return nil, errors.New("lastStatusCode must be an HTTP status code")
+	}
+	return &statusCode, nil
+}
+
+func isJSONObject(value string) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal([]byte(value), &object) == nil && object != nil
+}
+
 func validDeliveryStatus(status string) bool {
 	switch status {
 	case "", webhooks.StatusDeliveryPending, webhooks.StatusDeliveryDelivering,
@@ -67,13 +84,21 @@ func (h *serverHandler) getDeliveriesHandle(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		switch key {
-		case "configId", "status", "createdAtFrom", "createdAtTo", "cursor", "pageSize":
+		case "configId", "status", "eventId", "idempotencyKey", "eventType", "lastStatusCode", "payload", "scheduled",
+			"createdAtFrom", "createdAtTo", "cursor", "pageSize":
 		default:
 			apierrors.ResponseError(w, r, apierrors.NewValidationError("unsupported query parameter: "+key))
 			return
 		}
 	}
-	filter := webhooks.DeliveryFilter{ConfigID: query.Get("configId"), Status: query.Get("status")}
+	filter := webhooks.DeliveryFilter{
+		ConfigID:        query.Get("configId"),
+		Status:          query.Get("status"),
+		EventID:         query.Get("eventId"),
+		IdempotencyKey:  query.Get("idempotencyKey"),
+		EventType:       strings.ToLower(query.Get("eventType")),
+		PayloadContains: query.Get("payload"),
+	}
 	if filter.ConfigID != "" {
 		if _, err := uuid.Parse(filter.ConfigID); err != nil {
 			apierrors.ResponseError(w, r, apierrors.NewValidationError("configId must be a UUID"))
@@ -84,8 +109,23 @@ func (h *serverHandler) getDeliveriesHandle(w http.ResponseWriter, r *http.Reque
 		apierrors.ResponseError(w, r, apierrors.NewValidationError("invalid delivery status"))
 		return
 	}
+	if filter.PayloadContains != "" && !isJSONObject(filter.PayloadContains) {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("payload must be a JSON object"))
+		return
+	}
+	if scheduled := query.Get("scheduled"); scheduled != "" {
+		value, err := strconv.ParseBool(scheduled)
+		if err != nil {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("scheduled must be a boolean"))
+			return
+		}
+		filter.Scheduled = value
+	}
 	var err error
-	filter.CreatedAfter, err = parseOptionalTime(query.Get("createdAtFrom"))
+	filter.LastStatusCode, err = parseOptionalStatusCode(query.Get("lastStatusCode"))
+	if err == nil {
+		filter.CreatedAfter, err = parseOptionalTime(query.Get("createdAtFrom"))
+	}
 	if err == nil {
 		filter.CreatedBefore, err = parseOptionalTime(query.Get("createdAtTo"))
 	}
@@ -186,9 +226,10 @@ func requireIdempotencyKey(r *http.Request) (string, error) {
 
 func replayError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
-	case errors.Is(err, storage.ErrDeliveryNotFound):
+	case errors.Is(err, storage.ErrDeliveryNotFound), errors.Is(err, storage.ErrConfigNotFound):
 		apierrors.ResponseError(w, r, apierrors.NewNotFoundError(err.Error()))
-	case errors.Is(err, storage.ErrDeliveryNotReplayable), errors.Is(err, storage.ErrIdempotencyConflict):
+	case errors.Is(err, storage.ErrDeliveryNotReplayable), errors.Is(err, storage.ErrDeliveryNotReschedulable),
+		errors.Is(err, storage.ErrIdempotencyConflict):
 		apierrors.ResponseError(w, r, apierrors.NewConflictError(err.Error()))
 	default:
 		apierrors.ResponseError(w, r, err)
@@ -201,7 +242,21 @@ func (h *serverHandler) replayDeliveryHandle(w http.ResponseWriter, r *http.Requ
 		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
 		return
 	}
-	delivery, applied, err := h.store.ReplayDelivery(r.Context(), chi.URLParam(r, PathParamId), key)
+	request := webhooks.ReplayDeliveryRequest{}
+	if err := decodeJSONBody(r, &request, true); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	var delivery webhooks.Delivery
+	var applied bool
+	if request.TargetConfigID == "" {
+		delivery, applied, err = h.store.ReplayDelivery(r.Context(), chi.URLParam(r, PathParamId), key)
+	} else if _, parseErr := uuid.Parse(request.TargetConfigID); parseErr != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("targetConfigId must be a UUID"))
+		return
+	} else {
+		delivery, applied, err = h.store.ReplayDeliveryToConfig(r.Context(), chi.URLParam(r, PathParamId), request.TargetConfigID, key)
+	}
 	if err != nil {
 		replayError(w, r, err)
 		return
@@ -216,6 +271,35 @@ func (h *serverHandler) replayDeliveryHandle(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+func (h *serverHandler) rescheduleDeliveryHandle(w http.ResponseWriter, r *http.Request) {
+	key, err := requireIdempotencyKey(r)
+	if err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	request := webhooks.RescheduleDeliveryRequest{}
+	if err := decodeJSONBody(r, &request, true); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if request.NextAttemptAt != nil && request.NextAttemptAt.After(time.Now().Add(maxReplayWindow)) {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("nextAttemptAt must be within 90 days"))
+		return
+	}
+	delivery, applied, err := h.store.RescheduleDelivery(r.Context(), chi.URLParam(r, PathParamId), request, key)
+	if err != nil {
+		replayError(w, r, err)
+		return
+	}
+	if applied {
+		logging.FromContext(r.Context()).Infof("rescheduled delivery %s at %s", delivery.ID, delivery.NextAttemptAt.Format(time.RFC3339))
+		metrics.RecordReplay(r.Context(), "individual", "rescheduled", 1)
+	}
+	if err := json.NewEncoder(w).Encode(api.BaseResponse[webhooks.Delivery]{Data: &delivery}); err != nil {
+		apierrors.ResponseError(w, r, err)
+	}
+}
+
 func (h *serverHandler) replayDeliveriesHandle(w http.ResponseWriter, r *http.Request) {
 	key, err := requireIdempotencyKey(r)
 	if err != nil {
@@ -255,6 +339,12 @@ func (h *serverHandler) replayDeliveriesHandle(w http.ResponseWriter, r *http.Re
 		}
 	}
 	sort.Strings(request.ConfigIDs)
+	if request.TargetConfigID != "" {
+		if _, err := uuid.Parse(request.TargetConfigID); err != nil {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("targetConfigId must be a UUID"))
+			return
+		}
+	}
 	replayCursor, err := webhooks.DecodeReplayDeliveryCursor(request.CursorToken)
 	if err != nil {
 		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
@@ -267,7 +357,8 @@ func (h *serverHandler) replayDeliveriesHandle(w http.ResponseWriter, r *http.Re
 		if !request.CreatedAtFrom.Equal(replayCursor.CreatedAtFrom) ||
 			!request.CreatedAtTo.Equal(replayCursor.CreatedAtTo) ||
 			!slices.Equal(request.Statuses, replayCursor.Statuses) ||
-			!slices.Equal(request.ConfigIDs, replayCursor.ConfigIDs) {
+			!slices.Equal(request.ConfigIDs, replayCursor.ConfigIDs) ||
+			request.TargetConfigID != replayCursor.TargetConfigID {
 			apierrors.ResponseError(w, r, apierrors.NewValidationError("replay cursor does not match request filters"))
 			return
 		}
@@ -290,7 +381,7 @@ func (h *serverHandler) replayDeliveriesHandle(w http.ResponseWriter, r *http.Re
 		if result.NextCursor != nil {
 			result.NextCursorToken, err = webhooks.EncodeReplayDeliveryCursor(webhooks.ReplayDeliveryCursor{
 				Position: *result.NextCursor, CreatedAtFrom: request.CreatedAtFrom, CreatedAtTo: result.CreatedAtTo,
-				Statuses: request.Statuses, ConfigIDs: request.ConfigIDs,
+				Statuses: request.Statuses, ConfigIDs: request.ConfigIDs, TargetConfigID: request.TargetConfigID,
 			})
 		}
 		if err != nil {
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/api"
+	"github.com/formancehq/go-libs/v2/logging"
+	"github.com/formancehq/go-libs/v2/publish"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/server/apierrors"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+func (h *serverHandler) publishEventHandle(w http.ResponseWriter, r *http.Request) {
+	request := webhooks.PublishEventRequest{}
+	if err := decodeJSONBody(r, &request, false); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(errors.Wrap(err, "invalid event").Error()))
+		return
+	}
+	if request.ID == "" {
+		request.ID = uuid.NewString()
+	}
+	now := time.Now().UTC()
+	// Store the event exactly as a broker event would be after normalization,
+	// so receivers cannot tell the two ingestion paths apart.
+	event := publish.EventMessage{
+		IdempotencyKey: request.IdempotencyKey,
+		Date:           now,
+		App:            request.App,
+		Version:        request.Version,
+		Type:           webhooks.NormalizeEventType(request.App, request.Type),
+		Payload:        request.Payload,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		apierrors.ResponseError(w, r, err)
+		return
+	}
+	var deliverAt time.Time
+	if request.DeliverAt != nil {
+		deliverAt = request.DeliverAt.UTC()
+	}
+	result, err := h.store.EnqueueEvent(r.Context(), request.ID, event.IdempotencyKey, event.Type, string(payload), now, deliverAt)
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("POST %s: %s", PathEvents, err)
+		apierrors.ResponseError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Debugf("POST %s: event %s matched %d configs", PathEvents, result.EventID, len(result.Matches))
+	if err := json.NewEncoder(w).Encode(api.BaseResponse[webhooks.EnqueuedEvent]{Data: &result}); err != nil {
+		apierrors.ResponseError(w, r, err)
+	}
+}
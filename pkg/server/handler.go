@@ -11,20 +11,31 @@ import (
 
 	"github.com/formancehq/go-libs/v2/auth"
 	"github.com/formancehq/go-libs/v2/logging"
+	"github.com/formancehq/webhooks/pkg/health"
 	"github.com/formancehq/webhooks/pkg/storage"
 )
 
 const (
 	PathHealthCheck  = "/_healthcheck"
+	PathLive         = "/_live"
+	PathReady        = "/_ready"
 	PathInfo         = "/_info"
 	PathConfigs      = "/configs"
 	PathTest         = "/test"
 	PathActivate     = "/activate"
 	PathDeactivate   = "/deactivate"
+	PathPause        = "/pause"
+	PathResume       = "/resume"
 	PathChangeSecret = "/secret/change"
 	PathDeliveries   = "/deliveries"
 	PathAttempts     = "/attempts"
 	PathReplay       = "/replay"
+	PathStream       = "/stream"
+	PathCancel       = "/cancel"
+	PathReschedule   = "/reschedule"
+	PathBackfill     = "/backfill"
+	PathEvents       = "/events"
+	PathWorkers      = "/workers"
 	PathId           = "/{" + PathParamId + "}"
 	PathParamId      = "id"
 )
@@ -43,8 +54,10 @@ func newServerHandler(
 	info ServiceInfo,
 	authenticator auth.Authenticator,
 	publisher message.Publisher,
+	checks health.Checks,
 	debug bool,
 	auditEnabled bool,
+	authEnabled bool,
 ) http.Handler {
 	h := &serverHandler{
 		Mux:        chi.NewRouter(),
@@ -72,10 +85,13 @@ func newServerHandler(
 		})
 	})
 	h.Get(PathHealthCheck, h.HealthCheckHandle)
+	h.Get(PathLive, health.LiveHandle)
+	h.Get(PathReady, health.ReadyHandler(checks))
 	h.Get(PathInfo, h.getInfo(info))
 
 	h.Group(func(r chi.Router) {
 		r.Use(auth.Middleware(authenticator))
+		r.Use(identifyCallers(authEnabled))
 		r.Use(service.OTLPMiddleware("webhooks", debug))
 
 		r.Get(PathConfigs, h.getManyConfigsHandle)
@@ -85,12 +101,21 @@ func newServerHandler(
 		r.Get(PathConfigs+PathId+PathTest, h.testOneConfigHandle)
 		r.Put(PathConfigs+PathId+PathActivate, h.activateOneConfigHandle)
 		r.Put(PathConfigs+PathId+PathDeactivate, h.deactivateOneConfigHandle)
+		r.Put(PathConfigs+PathId+PathPause, h.pauseOneConfigHandle)
+		r.Put(PathConfigs+PathId+PathResume, h.resumeOneConfigHandle)
 		r.Put(PathConfigs+PathId+PathChangeSecret, h.changeSecretHandle)
+		r.Post(PathConfigs+PathId+PathBackfill, h.backfillOneConfigHandle)
+		r.Post(PathEvents, h.publishEventHandle)
 		r.Get(PathDeliveries, h.getDeliveriesHandle)
+		r.Get(PathDeliveries+PathStream, h.streamDeliveriesHandle)
 		r.Post(PathDeliveries+PathReplay, h.replayDeliveriesHandle)
+		r.Post(PathDeliveries+PathCancel, h.cancelDeliveriesHandle)
 		r.Get(PathDeliveries+PathId, h.getDeliveryHandle)
 		r.Get(PathDeliveries+PathId+PathAttempts, h.getDeliveryAttemptsHandle)
 		r.Post(PathDeliveries+PathId+PathReplay, h.replayDeliveryHandle)
+		r.Post(PathDeliveries+PathId+PathReschedule, h.rescheduleDeliveryHandle)
+		r.Post(PathDeliveries+PathId+PathCancel, h.cancelDeliveryHandle)
+		r.Get(PathWorkers, h.getWorkersHandle)
 	})
 
 	return h
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/formancehq/go-libs/v2/auth"
+	"github.com/formancehq/webhooks/pkg/health"
 	"github.com/formancehq/webhooks/pkg/storage"
 
 	"github.com/formancehq/go-libs/v2/httpserver"
@@ -25,6 +26,7 @@ func FXModuleFromFlags(cmd *cobra.Command, addr string, debug bool, auditEnabled
 		otlptraces.FXModuleFromFlags(cmd),
 	)
 
+	authEnabled, _ := cmd.Flags().GetBool(auth.AuthEnabledFlag)
 	options = append(options, fx.Provide(
 		func(
 			store storage.Store,
@@ -33,8 +35,9 @@ func FXModuleFromFlags(cmd *cobra.Command, addr string, debug bool, auditEnabled
 			info ServiceInfo,
 			authenticator auth.Authenticator,
 			publisher message.Publisher,
+			checks health.Checks,
 		) http.Handler {
-			return newServerHandler(store, httpClient, logger, info, authenticator, publisher, debug, auditEnabled)
+			return newServerHandler(store, httpClient, logger, info, authenticator, publisher, checks, debug, auditEnabled, authEnabled)
 		},
 	), fx.Invoke(func(lc fx.Lifecycle, handler http.Handler) {
 		lc.Append(httpserver.NewHook(handler, httpserver.WithAddress(addr)))
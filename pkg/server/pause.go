@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/formancehq/go-libs/v2/api"
+	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/server/apierrors"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/pkg/errors"
+)
+
+func (h *serverHandler) pauseOneConfigHandle(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, PathParamId)
+	pause := webhooks.ConfigPause{}
+	if err := decodeJSONBody(r, &pause, true); err != nil {
+		logging.FromContext(r.Context()).Errorf("decodeJSONBody: %s", err)
+		apierrors.ResponseError(w, r, apierrors.NewValidationError(err.Error()))
+		return
+	}
+	if pause.ResumeAt != nil && !pause.ResumeAt.After(time.Now()) {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("resumeAt should be in the future"))
+		return
+	}
+
+	c, err := h.store.PauseOneConfig(r.Context(), id, pause.ResumeAt)
+	if err == nil {
+		logging.FromContext(r.Context()).Debugf("PUT %s/%s%s", PathConfigs, id, PathPause)
+		writeConfig(w, r, c)
+	} else if errors.Is(err, storage.ErrConfigNotFound) {
+		logging.FromContext(r.Context()).Debugf("PUT %s/%s%s: %s", PathConfigs, id, PathPause, storage.ErrConfigNotFound)
+		apierrors.ResponseError(w, r, apierrors.NewNotFoundError(storage.ErrConfigNotFound.Error()))
+	} else {
+		logging.FromContext(r.Context()).Errorf("PUT %s/%s%s: %s", PathConfigs, id, PathPause, err)
+		apierrors.ResponseError(w, r, err)
+	}
+}
+
+func (h *serverHandler) resumeOneConfigHandle(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, PathParamId)
+	c, err := h.store.ResumeOneConfig(r.Context(), id)
+	if err == nil || errors.Is(err, storage.ErrConfigNotModified) {
+		logging.FromContext(r.Context()).Debugf("PUT %s/%s%s", PathConfigs, id, PathResume)
+		writeConfig(w, r, c)
+	} else if errors.Is(err, storage.ErrConfigNotFound) {
+		logging.FromContext(r.Context()).Debugf("PUT %s/%s%s: %s", PathConfigs, id, PathResume, storage.ErrConfigNotFound)
+		apierrors.ResponseError(w, r, apierrors.NewNotFoundError(storage.ErrConfigNotFound.Error()))
+	} else {
+		logging.FromContext(r.Context()).Errorf("PUT %s/%s%s: %s", PathConfigs, id, PathResume, err)
+		apierrors.ResponseError(w, r, err)
+	}
+}
+
+func writeConfig(w http.ResponseWriter, r *http.Request, c webhooks.Config) {
+	resp := api.BaseResponse[webhooks.Config]{
+		Data: &c,
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Errorf("json.Encoder.Encode: %s", err)
+		apierrors.ResponseError(w, r, err)
+	}
+}
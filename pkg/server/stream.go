@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/server/apierrors"
+	"github.com/google/uuid"
+)
+
+const (
+	// deliveryEventPollPeriod is how often a stream reads new events. Events
+	// are not pushed by the database, so that recording them does not add a
+	// NOTIFY to every delivery transaction.
+	deliveryEventPollPeriod     = time.Second
+	deliveryEventReconnectDelay = 5 * time.Second
+	deliveryEventHeartbeat      = 15 * time.Second
+	deliveryEventPageSize       = 500
+)
+
+func (h *serverHandler) streamDeliveriesHandle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	for key, values := range query {
+		if len(values) != 1 {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("query parameters must have one value"))
+			return
+		}
+		switch key {
+		case "configId", "status":
+		default:
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("unsupported query parameter: "+key))
+			return
+		}
+	}
+	filter := webhooks.DeliveryEventFilter{ConfigID: query.Get("configId"), Status: query.Get("status"), Limit: deliveryEventPageSize}
+	if filter.ConfigID != "" {
+		if _, err := uuid.Parse(filter.ConfigID); err != nil {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("configId must be a UUID"))
+			return
+		}
+	}
+	if !validDeliveryStatus(filter.Status) {
+		apierrors.ResponseError(w, r, apierrors.NewValidationError("invalid delivery status"))
+		return
+	}
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		after, err := webhooks.DecodeDeliveryEventPosition(lastEventID)
+		if err != nil {
+			apierrors.ResponseError(w, r, apierrors.NewValidationError("Last-Event-ID must be a delivery event ID"))
+			return
+		}
+		filter.After = after
+	} else {
+		head, err := h.store.DeliveryEventsHead(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("GET %s%s: %s", PathDeliveries, PathStream, err)
+			apierrors.ResponseError(w, r, err)
+			return
+		}
+		filter.After = head
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", deliveryEventReconnectDelay.Milliseconds()); err != nil {
+		return
+	}
+	if err := controller.Flush(); err != nil {
+		logging.FromContext(r.Context()).Errorf("GET %s%s: streaming unsupported: %s", PathDeliveries, PathStream, err)
+		return
+	}
+
+	poll := time.NewTicker(deliveryEventPollPeriod)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(deliveryEventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		if err := h.writeDeliveryEvents(w, r, &filter); err != nil {
+			logging.FromContext(r.Context()).Debugf("GET %s%s: %s", PathDeliveries, PathStream, err)
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-poll.C:
+		}
+	}
+}
+
+// writeDeliveryEvents writes the events after filter.After and advances it.
+func (h *serverHandler) writeDeliveryEvents(w http.ResponseWriter, r *http.Request, filter *webhooks.DeliveryEventFilter) error {
+	for {
+		events, err := h.store.FindDeliveryEvents(r.Context(), *filter)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			position := event.Position()
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n",
+				webhooks.EncodeDeliveryEventPosition(position), event.Type, data); err != nil {
+				return err
+			}
+			filter.After = position
+		}
+		if len(events) < filter.Limit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/formancehq/go-libs/v2/api"
+	"github.com/formancehq/go-libs/v2/bun/bunpaginate"
+	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/server/apierrors"
+)
+
+func (h *serverHandler) getWorkersHandle(w http.ResponseWriter, r *http.Request) {
+	workers, err := h.store.ListWorkers(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("GET %s: %s", PathWorkers, err)
+		apierrors.ResponseError(w, r, err)
+		return
+	}
+	resp := api.BaseResponse[webhooks.Worker]{
+		Cursor: &bunpaginate.Cursor[webhooks.Worker]{
+			Data: workers,
+		},
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		apierrors.ResponseError(w, r, err)
+	}
+}
@@ -11,6 +11,16 @@ import (
 )
 
 func Migrate(ctx context.Context, db *bun.DB) error {
+	return newMigrator(db).Up(ctx)
+}
+
+// IsUpToDate reports whether every migration has been applied.
+func IsUpToDate(ctx context.Context, db *bun.DB) (bool, error) {
+	upToDate, err := newMigrator(db).IsUpToDate(ctx)
+	return upToDate, errors.Wrap(err, "checking migrations")
+}
+
+func newMigrator(db *bun.DB) *migrations.Migrator {
 	migrator := migrations.NewMigrator(db)
 	migrator.RegisterMigrations(
 		migrations.Migration{
@@ -225,7 +235,316 @@ func Migrate(ctx context.Context, db *bun.DB) error {
 				return errors.Wrap(err, "creating durable delivery constraints and indexes")
 			},
 		},
+		migrations.Migration{
+			Name: "Add config draining state",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.NewAddColumn().
+					Table("configs").
+					ColumnExpr("draining_since timestamptz").
+					IfNotExists().
+					Exec(ctx)
+				return errors.Wrap(err, "adding configs.draining_since")
+			},
+		},
+		migrations.Migration{
+			Name: "Add config pause state",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.ExecContext(ctx, `
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS paused_at timestamptz;
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS resume_at timestamptz;
+					CREATE INDEX IF NOT EXISTS idx_configs_resume_at
+						ON configs (resume_at) WHERE paused_at IS NOT NULL;
+				`)
+				return errors.Wrap(err, "adding config pause columns")
+			},
+		},
+		migrations.Migration{
+			Name: "Add config maintenance windows",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.ExecContext(ctx, `
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS maintenance_windows jsonb;
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS maintenance_starts_at timestamptz;
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS maintenance_ends_at timestamptz;
+					CREATE INDEX IF NOT EXISTS idx_configs_maintenance_ends_at
+						ON configs (maintenance_ends_at) WHERE deleted_at IS NULL;
+				`)
+				return errors.Wrap(err, "adding config maintenance window columns")
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery events feed",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.ExecContext(ctx, `
+					CREATE TABLE IF NOT EXISTS delivery_events (
+						id bigserial PRIMARY KEY,
+						type varchar NOT NULL,
+						delivery_id varchar NOT NULL,
+						config_id varchar NOT NULL,
+						status varchar,
+						attempt_id varchar,
+						outcome varchar,
+						status_code integer,
+						xid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+						created_at timestamptz NOT NULL DEFAULT clock_timestamp()
+					);
+					CREATE INDEX IF NOT EXISTS idx_delivery_events_position
+						ON delivery_events (xid, id);
+					CREATE INDEX IF NOT EXISTS idx_delivery_events_config
+						ON delivery_events (config_id, xid, id);
+					CREATE INDEX IF NOT EXISTS idx_delivery_events_created
+						ON delivery_events (created_at);
+				`)
+				return errors.Wrap(err, "creating delivery events feed")
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery search indexes",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_idempotency_key
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery idempotency key index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_idempotency_key
+						ON deliveries (idempotency_key) WHERE idempotency_key IS NOT NULL
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery idempotency key index")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_event_type_created
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery event type index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_event_type_created
+						ON deliveries (event_type, created_at, id)
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery event type index")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_last_status_code_created
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery last status code index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_last_status_code_created
+						ON deliveries (last_status_code, created_at, id) WHERE last_status_code IS NOT NULL
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery last status code index")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_payload
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery payload index before rebuild")
+				}
+				// jsonb_path_ops only serves containment (@>), the one payload
+				// search, and is smaller and cheaper to maintain than jsonb_ops.
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_payload
+						ON deliveries USING gin ((payload::jsonb) jsonb_path_ops)
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery payload index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery attempt capture",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.ExecContext(ctx, `
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS capture_attempts boolean NOT NULL DEFAULT false;
+					ALTER TABLE delivery_attempts ADD COLUMN IF NOT EXISTS capture jsonb;
+				`)
+				return errors.Wrap(err, "adding delivery attempt capture")
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery cancellation metadata",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.ExecContext(ctx, `
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS cancelled_by varchar;
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS cancellation_reason varchar;
+				`)
+				return errors.Wrap(err, "adding delivery cancellation metadata")
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery replay lineage",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS replayed_from varchar
+						REFERENCES deliveries(id) ON DELETE SET NULL
+				`); err != nil {
+					return errors.Wrap(err, "adding delivery replay lineage")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_replayed_from
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery replay lineage index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_replayed_from
+						ON deliveries (replayed_from) WHERE replayed_from IS NOT NULL
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery replay lineage index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add scheduled deliveries",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS deliver_at timestamptz
+				`); err != nil {
+					return errors.Wrap(err, "adding scheduled deliveries")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_scheduled
+				`); err != nil {
+					return errors.Wrap(err, "dropping scheduled deliveries index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_scheduled
+						ON deliveries (deliver_at, id) WHERE status = 'pending' AND deliver_at IS NOT NULL
+				`); err != nil {
+					return errors.Wrap(err, "creating scheduled deliveries index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery priorities",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS priority varchar;
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS event_type_priorities jsonb;
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS priority varchar NOT NULL DEFAULT 'normal';
+				`); err != nil {
+					return errors.Wrap(err, "adding delivery priorities")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_pending_priority_due
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery priority claim index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_pending_priority_due
+						ON deliveries (priority, next_attempt_at, id) WHERE status = 'pending'
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery priority claim index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add per-config claim index",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_pending_config_priority_due
+				`); err != nil {
+					return errors.Wrap(err, "dropping per-config claim index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_pending_config_priority_due
+						ON deliveries (config_id, priority, next_attempt_at, id) WHERE status = 'pending'
+				`); err != nil {
+					return errors.Wrap(err, "creating per-config claim index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add delivery claim leases",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				// Claims taken before leases existed keep the former fixed
+				// five minutes recovery delay.
+				if _, err := tx.ExecContext(ctx, `
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS claimed_by varchar;
+					ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS lease_expires_at timestamptz;
+					UPDATE deliveries SET lease_expires_at = claimed_at + interval '5 minutes'
+						WHERE status = 'delivering' AND lease_expires_at IS NULL;
+				`); err != nil {
+					return errors.Wrap(err, "adding delivery claim leases")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_delivering_lease
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery claim lease index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_delivering_lease
+						ON deliveries (lease_expires_at, id) WHERE status = 'delivering'
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery claim lease index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add workers registry",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					CREATE TABLE IF NOT EXISTS workers (
+						id varchar PRIMARY KEY,
+						hostname varchar NOT NULL,
+						version varchar,
+						started_at timestamptz NOT NULL,
+						last_heartbeat_at timestamptz NOT NULL
+					);
+					ALTER TABLE delivery_attempts ADD COLUMN IF NOT EXISTS worker_id varchar;
+				`); err != nil {
+					return errors.Wrap(err, "adding workers registry")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_delivering_claimed_by
+				`); err != nil {
+					return errors.Wrap(err, "dropping delivery claim owner index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_delivering_claimed_by
+						ON deliveries (claimed_by) WHERE status = 'delivering'
+				`); err != nil {
+					return errors.Wrap(err, "creating delivery claim owner index")
+				}
+				return nil
+			},
+		},
+		migrations.Migration{
+			Name: "Add monitored configs",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				_, err := tx.ExecContext(ctx, `
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS monitor boolean NOT NULL DEFAULT false;
+				`)
+				return errors.Wrap(err, "adding monitored configs")
+			},
+		},
+		migrations.Migration{
+			Name: "Add config failure policy",
+			Up: func(ctx context.Context, tx bun.IDB) error {
+				if _, err := tx.ExecContext(ctx, `
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS owner_contact_url varchar;
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS disabled_reason varchar;
+					ALTER TABLE configs ADD COLUMN IF NOT EXISTS consecutive_failures integer NOT NULL DEFAULT 0;
+				`); err != nil {
+					return errors.Wrap(err, "adding config failure policy")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					DROP INDEX CONCURRENTLY IF EXISTS idx_deliveries_config_finished
+				`); err != nil {
+					return errors.Wrap(err, "dropping config finished deliveries index before rebuild")
+				}
+				if _, err := tx.ExecContext(ctx, `
+					CREATE INDEX CONCURRENTLY idx_deliveries_config_finished
+						ON deliveries (config_id, updated_at) WHERE status IN ('succeeded', 'failed')
+				`); err != nil {
+					return errors.Wrap(err, "creating config finished deliveries index")
+				}
+				return nil
+			},
+		},
 	)
 
-	return migrator.Up(ctx)
+	return migrator
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/formancehq/go-libs/v2/publish"
@@ -15,81 +16,224 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
 )
 
-const maxReplayPageSize = 1000
+const (
+	maxReplayPageSize = 1000
+	// deliveriesDueChannel is notified when a transaction makes deliveries
+	// due, so that idle dispatchers do not wait for their next tick.
+	deliveriesDueChannel = "deliveries_due"
+)
+
+// notifyDeliveriesDue wakes the dispatchers once tx commits.
+func notifyDeliveriesDue(ctx context.Context, tx bun.IDB) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_notify(?, '')", deliveriesDueChannel)
+	return errors.Wrap(err, "notifying due deliveries")
+}
 
-func (s Store) EnqueueEvent(ctx context.Context, eventID, idempotencyKey, eventType, payload string, createdAt time.Time) error {
+// ListenDeliveriesDue calls notify each time deliveries were made due by any
+// replica, until ctx is done.
+func (s Store) ListenDeliveriesDue(ctx context.Context, notify func()) error {
+	return s.listen(ctx, deliveriesDueChannel, func(string) { notify() })
+}
+
+func (s Store) EnqueueEvent(ctx context.Context, eventID, idempotencyKey, eventType, payload string, createdAt, deliverAt time.Time) (webhooks.EnqueuedEvent, error) {
+	result := webhooks.EnqueuedEvent{EventID: eventID, EventType: eventType, Matches: []webhooks.EventMatch{}}
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.Wrap(err, "beginning event enqueue transaction")
+		return result, errors.Wrap(err, "beginning event enqueue transaction")
 	}
 	defer func() { _ = tx.Rollback() }()
 	configs := []webhooks.Config{}
 	if err := tx.NewSelect().Model(&configs).
 		Where("? = ANY (event_types)", eventType).
-		Where("active = true AND deleted_at IS NULL").
+		Where("active = true AND deleted_at IS NULL AND draining_since IS NULL").
 		For("SHARE").Scan(ctx); err != nil {
-		return errors.Wrap(err, "selecting configs for event enqueue")
+		return result, errors.Wrap(err, "selecting configs for event enqueue")
+	}
+	if len(configs) == 0 {
+		return result, errors.Wrap(tx.Commit(), "committing event enqueue")
 	}
 	deliveries := make([]webhooks.Delivery, 0, len(configs))
+	configIDs := make([]string, 0, len(configs))
+	var scheduledAt *time.Time
+	if deliverAt.After(createdAt) {
+		scheduledAt = &deliverAt
+	}
 	for _, config := range configs {
 		nextAttemptAt := createdAt
+		if scheduledAt != nil {
+			nextAttemptAt = *scheduledAt
+		}
 		deliveries = append(deliveries, webhooks.Delivery{
 			ID: uuid.NewString(), EventID: eventID, IdempotencyKey: idempotencyKey,
 			ConfigID: config.ID, EventType: eventType, Payload: payload,
-			Status: webhooks.StatusDeliveryPending, NextAttemptAt: &nextAttemptAt,
+			Status: webhooks.StatusDeliveryPending, Priority: config.PriorityFor(eventType),
+			DeliverAt: scheduledAt, NextAttemptAt: &nextAttemptAt,
 			CreatedAt: createdAt, UpdatedAt: createdAt,
 		})
+		configIDs = append(configIDs, config.ID)
+	}
+	inserted := []webhooks.Delivery{}
+	if err := withDeliveryEvents(tx, tx.NewInsert().Model(&deliveries).
+		On("CONFLICT (event_id, config_id) DO NOTHING").
+		Returning("id, config_id, status")).Scan(ctx, &inserted); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return result, errors.Wrap(err, "inserting event deliveries")
+	}
+	// A redelivered event conflicts on (event_id, config_id): report the
+	// deliveries it produced the first time.
+	existing := []webhooks.Delivery{}
+	if err := tx.NewSelect().Model(&existing).Column("id", "config_id").
+		Where("event_id = ?", eventID).
+		Where("config_id IN (?)", bun.List(configIDs)).
+		Order("config_id").Scan(ctx); err != nil {
+		return result, errors.Wrap(err, "selecting event deliveries")
+	}
+	for _, delivery := range existing {
+		result.Matches = append(result.Matches, webhooks.EventMatch{
+			ConfigID: delivery.ConfigID, DeliveryID: delivery.ID,
+			Duplicate: !slices.ContainsFunc(inserted, func(d webhooks.Delivery) bool { return d.ID == delivery.ID }),
+		})
 	}
-	if len(deliveries) > 0 {
-		if _, err := tx.NewInsert().Model(&deliveries).
-			On("CONFLICT (event_id, config_id) DO NOTHING").Exec(ctx); err != nil {
-			return errors.Wrap(err, "inserting event deliveries")
+	if len(inserted) > 0 && scheduledAt == nil {
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return result, err
 		}
 	}
-	return errors.Wrap(tx.Commit(), "committing event enqueue")
+	return result, errors.Wrap(tx.Commit(), "committing event enqueue")
 }
 
-func (s Store) ClaimDeliveries(ctx context.Context, limit int) ([]webhooks.Delivery, error) {
+// ClaimDeliveries claims due deliveries lane by lane, each lane up to its
+// share of limit, then fills what is left of limit in priority order. Within
+// a lane, the claim is shared evenly between the configs with due deliveries.
+// Claims are recorded under the lease worker and expire after its duration
+// unless extended.
+func (s Store) ClaimDeliveries(ctx context.Context, limit int, lease webhooks.ClaimLease) ([]webhooks.Delivery, error) {
 	if limit <= 0 {
 		limit = 50
 	}
+	if lease.Duration <= 0 {
+		lease.Duration = webhooks.DefaultClaimLeaseDuration
+	}
 	res := []webhooks.Delivery{}
-	err := s.db.NewRaw(`
-		WITH candidates AS (
-			SELECT d.id
-			FROM deliveries d
-			JOIN configs c ON c.id = d.config_id
-			WHERE d.status = ?
-			  AND d.next_attempt_at <= NOW()
-			  AND c.active = true
+	for i, quota := range webhooks.PriorityQuotas(limit) {
+		if quota == 0 {
+			continue
+		}
+		claimed, err := s.claimDeliveries(ctx, quota, webhooks.Priorities[i], lease)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, claimed...)
+	}
+	// Lanes with fewer due deliveries than their share, and configs holding
+	// back a backlog for fairness, leave capacity for the others.
+	for _, priority := range webhooks.Priorities {
+		for len(res) < limit {
+			claimed, err := s.claimDeliveries(ctx, limit-len(res), priority, lease)
+			if err != nil {
+				return res, err
+			}
+			if len(claimed) == 0 {
+				break
+			}
+			res = append(res, claimed...)
+		}
+	}
+	return res, nil
+}
+
+// dispatchableConfig matches the configs c whose due deliveries can be
+// claimed: active, not deleted, not paused and out of maintenance. A draining
+// config ignores its pause, or it would never be finalized.
+const dispatchableConfig = `c.active = true
 			  AND c.deleted_at IS NULL
-			ORDER BY d.next_attempt_at, d.id
-			FOR UPDATE OF d SKIP LOCKED
+			  AND (c.paused_at IS NULL OR c.resume_at <= NOW() OR c.draining_since IS NOT NULL)
+			  AND NOT COALESCE(c.maintenance_starts_at <= NOW() AND c.maintenance_ends_at > NOW(), false)`
+
+// claimDeliveries claims up to limit due deliveries of one priority, round
+// robin between the configs that have some: every config gets its oldest due
+// delivery before any gets a second one. Each config locks at most
+// ceil(limit / due configs) rows, so a large backlog is never scanned.
+func (s Store) claimDeliveries(ctx context.Context, limit int, priority string, lease webhooks.ClaimLease) ([]webhooks.Delivery, error) {
+	res := []webhooks.Delivery{}
+	err := withDeliveryEvents(s.db, s.db.NewRaw(`
+		WITH due AS (
+			SELECT c.id
+			FROM configs c
+			WHERE `+dispatchableConfig+`
+			  AND EXISTS (
+				SELECT 1 FROM deliveries d
+				WHERE d.config_id = c.id AND d.status = ? AND d.priority = ? AND d.next_attempt_at <= NOW()
+			  )
+		), candidates AS (
+			SELECT pending.id, ROW_NUMBER() OVER (
+				PARTITION BY due.id ORDER BY pending.next_attempt_at, pending.id
+			) AS turn, pending.next_attempt_at
+			FROM due
+			CROSS JOIN LATERAL (
+				SELECT d.id, d.next_attempt_at
+				FROM deliveries d
+				WHERE d.config_id = due.id AND d.status = ? AND d.priority = ? AND d.next_attempt_at <= NOW()
+				ORDER BY d.next_attempt_at, d.id
+				LIMIT (SELECT CEIL(?::numeric / GREATEST(COUNT(*), 1))::int FROM due)
+				FOR UPDATE OF d SKIP LOCKED
+			) pending
+			ORDER BY turn, pending.next_attempt_at, pending.id
 			LIMIT ?
 		)
 		UPDATE deliveries d
 		SET status = ?,
 			claimed_at = NOW(),
+			claimed_by = NULLIF(?, ''),
+			lease_expires_at = NOW() + ? * interval '1 microsecond',
 			cycle_started_at = COALESCE(cycle_started_at, NOW()),
 			updated_at = NOW()
 		FROM candidates
 		WHERE d.id = candidates.id
 		RETURNING d.*
-	`, webhooks.StatusDeliveryPending, limit, webhooks.StatusDeliveryDelivering).Scan(ctx, &res)
+	`, webhooks.StatusDeliveryPending, priority, webhooks.StatusDeliveryPending, priority, limit, limit,
+		webhooks.StatusDeliveryDelivering, lease.WorkerID, lease.Duration.Microseconds())).Scan(ctx, &res)
 	return res, errors.Wrap(err, "claiming deliveries")
 }
 
-func (s Store) CompleteDelivery(ctx context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (string, error) {
+// ExtendDeliveryLeases pushes back the lease expiry of the deliveries the
+// worker still holds. Deliveries completed or taken over by another worker
+// meanwhile are left alone.
+func (s Store) ExtendDeliveryLeases(ctx context.Context, lease webhooks.ClaimLease, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if lease.Duration <= 0 {
+		lease.Duration = webhooks.DefaultClaimLeaseDuration
+	}
+	res, err := s.db.NewUpdate().Model((*webhooks.Delivery)(nil)).
+		Where("id IN (?)", bun.List(ids)).
+		Where("status = ?", webhooks.StatusDeliveryDelivering).
+		Where("claimed_by = ?", lease.WorkerID).
+		Set("lease_expires_at = NOW() + ? * interval '1 microsecond'", lease.Duration.Microseconds()).
+		Exec(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "extending delivery leases")
+	}
+	extended, err := res.RowsAffected()
+	return extended, errors.Wrap(err, "reading extended lease count")
+}
+
+func (s Store) CompleteDelivery(ctx context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (webhooks.DeliveryCompletion, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", errors.Wrap(err, "beginning delivery completion transaction")
+		return webhooks.DeliveryCompletion{}, errors.Wrap(err, "beginning delivery completion transaction")
 	}
 	defer func() { _ = tx.Rollback() }()
 	config := webhooks.Config{}
 	if err := tx.NewSelect().Model(&config).Where("id = ?", delivery.ConfigID).For("UPDATE").Scan(ctx); err != nil {
-		return "", errors.Wrap(err, "checking delivery config before completion")
+		if errors.Is(err, sql.ErrNoRows) {
+			// The config and its deliveries were purged while the attempt was in flight.
+			return webhooks.DeliveryCompletion{}, storage.ErrDeliveryNotFound
+		}
+		return webhooks.DeliveryCompletion{}, errors.Wrap(err, "checking delivery config before completion")
 	}
 	if delivery.Status != webhooks.StatusDeliverySucceeded && (!config.Active || config.DeletedAt != nil) {
 		delivery.Status = webhooks.StatusDeliveryCancelled
@@ -97,9 +241,12 @@ func (s Store) CompleteDelivery(ctx context.Context, delivery webhooks.Delivery,
 	}
 
 	if _, err := tx.NewInsert().Model(&attempt).Exec(ctx); err != nil {
-		return "", errors.Wrap(err, "inserting delivery attempt")
+		return webhooks.DeliveryCompletion{}, errors.Wrap(err, "inserting delivery attempt")
 	}
-	res, err := tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
+	if err := recordAttemptEvent(ctx, tx, delivery.ConfigID, attempt); err != nil {
+		return webhooks.DeliveryCompletion{}, err
+	}
+	res, err := withDeliveryEvents(tx, tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
 		Where("id = ?", delivery.ID).
 		Where("status = ?", webhooks.StatusDeliveryDelivering).
 		Where("claimed_at = ?", delivery.ClaimedAt).
@@ -107,34 +254,75 @@ func (s Store) CompleteDelivery(ctx context.Context, delivery webhooks.Delivery,
 		Set("attempt_count = ?", delivery.AttemptCount).
 		Set("cycle_started_at = ?", delivery.CycleStartedAt).
 		Set("next_attempt_at = ?", delivery.NextAttemptAt).
-		Set("claimed_at = NULL").
+		Set("claimed_at = NULL, claimed_by = NULL, lease_expires_at = NULL").
 		Set("last_attempt_at = ?", delivery.LastAttemptAt).
 		Set("last_status_code = ?", delivery.LastStatusCode).
 		Set("last_error = ?", delivery.LastError).
 		Set("updated_at = NOW()").
+		Returning("id, config_id, status")).
 		Exec(ctx)
 	if err != nil {
-		return "", errors.Wrap(err, "updating completed delivery")
+		return webhooks.DeliveryCompletion{}, errors.Wrap(err, "updating completed delivery")
 	}
 	affected, err := res.RowsAffected()
 	if err != nil {
-		return "", errors.Wrap(err, "reading completed delivery rows affected")
+		return webhooks.DeliveryCompletion{}, errors.Wrap(err, "reading completed delivery rows affected")
 	}
 	if affected != 1 {
-		return "", storage.ErrDeliveryNotFound
+		return webhooks.DeliveryCompletion{}, completeCancelledDelivery(ctx, tx, delivery)
+	}
+	failures, err := recordConfigOutcome(ctx, tx, config, delivery.Status)
+	if err != nil {
+		return webhooks.DeliveryCompletion{}, err
+	}
+	if config.DrainingSince != nil && delivery.Status != webhooks.StatusDeliveryPending {
+		if _, err := finalizeDrainedConfigs(ctx, tx, config.ID, time.Now().UTC()); err != nil {
+			return webhooks.DeliveryCompletion{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return webhooks.DeliveryCompletion{}, errors.Wrap(err, "committing delivery completion")
+	}
+	return webhooks.DeliveryCompletion{Status: delivery.Status, ConsecutiveFailures: failures}, nil
+}
+
+// completeCancelledDelivery keeps the attempt of a delivery cancelled while
+// the attempt was in flight, and commits tx. The delivery stays cancelled.
+func completeCancelledDelivery(ctx context.Context, tx bun.Tx, delivery webhooks.Delivery) error {
+	res, err := tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
+		Where("id = ?", delivery.ID).
+		Where("status = ?", webhooks.StatusDeliveryCancelled).
+		Where("attempt_count = ?", delivery.AttemptCount-1).
+		Set("attempt_count = ?", delivery.AttemptCount).
+		Set("last_attempt_at = ?", delivery.LastAttemptAt).
+		Set("last_status_code = ?", delivery.LastStatusCode).
+		Set("last_error = ?", delivery.LastError).
+		Set("updated_at = NOW()").
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "updating cancelled delivery")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "reading cancelled delivery rows affected")
+	}
+	if affected != 1 {
+		return storage.ErrDeliveryNotFound
 	}
 	if err := tx.Commit(); err != nil {
-		return "", errors.Wrap(err, "committing delivery completion")
+		return errors.Wrap(err, "committing cancelled delivery attempt")
 	}
-	return delivery.Status, nil
+	return storage.ErrDeliveryCancelled
 }
 
 func (s Store) CancelDelivery(ctx context.Context, id string) error {
-	res, err := s.db.NewUpdate().Model((*webhooks.Delivery)(nil)).
+	res, err := withDeliveryEvents(s.db, s.db.NewUpdate().Model((*webhooks.Delivery)(nil)).
 		Where("id = ?", id).
 		Where("status IN (?)", bun.List([]string{webhooks.StatusDeliveryPending, webhooks.StatusDeliveryDelivering})).
 		Set("status = ?", webhooks.StatusDeliveryCancelled).
-		Set("claimed_at = NULL, next_attempt_at = NULL, updated_at = NOW()").
+		Set("claimed_at = NULL, claimed_by = NULL, lease_expires_at = NULL").
+		Set("next_attempt_at = NULL, updated_at = NOW()").
+		Returning("id, config_id, status")).
 		Exec(ctx)
 	if err != nil {
 		return errors.Wrap(err, "cancelling delivery")
@@ -147,12 +335,14 @@ func (s Store) CancelDelivery(ctx context.Context, id string) error {
 }
 
 func (s Store) FailClaimedDelivery(ctx context.Context, id string, claimedAt time.Time, reason string) error {
-	res, err := s.db.NewUpdate().Model((*webhooks.Delivery)(nil)).
+	res, err := withDeliveryEvents(s.db, s.db.NewUpdate().Model((*webhooks.Delivery)(nil)).
 		Where("id = ?", id).
 		Where("status = ?", webhooks.StatusDeliveryDelivering).
 		Where("claimed_at = ?", claimedAt).
 		Set("status = ?", webhooks.StatusDeliveryFailed).
-		Set("claimed_at = NULL, next_attempt_at = NULL, last_error = ?, updated_at = NOW()", reason).
+		Set("claimed_at = NULL, claimed_by = NULL, lease_expires_at = NULL").
+		Set("next_attempt_at = NULL, last_error = ?, updated_at = NOW()", reason).
+		Returning("id, config_id, status")).
 		Exec(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failing claimed delivery")
@@ -167,29 +357,61 @@ func (s Store) FailClaimedDelivery(ctx context.Context, id string, claimedAt tim
 	return nil
 }
 
-func (s Store) RecoverStaleDeliveries(ctx context.Context, staleDuration time.Duration) (int64, error) {
-	if staleDuration <= 0 {
-		staleDuration = 5 * time.Minute
+// ReleaseClaimedDelivery hands a claimed delivery back to the queue without
+// recording an attempt, to be retried at nextAttemptAt. The retry cycle of a
+// delivery with attempts is shifted by postponed, so that the wait does not count
+// against the retry window.
+func (s Store) ReleaseClaimedDelivery(ctx context.Context, id string, claimedAt, nextAttemptAt time.Time, postponed time.Duration) error {
+	res, err := withDeliveryEvents(s.db, s.db.NewUpdate().Model((*webhooks.Delivery)(nil)).
+		Where("id = ?", id).
+		Where("status = ?", webhooks.StatusDeliveryDelivering).
+		Where("claimed_at = ?", claimedAt).
+		Set("status = ?", webhooks.StatusDeliveryPending).
+		Set("cycle_started_at = CASE WHEN attempt_count = 0 THEN NULL ELSE cycle_started_at + ?::interval END",
+			fmt.Sprintf("%d microseconds", postponed.Microseconds())).
+		Set("claimed_at = NULL, claimed_by = NULL, lease_expires_at = NULL").
+		Set("next_attempt_at = ?, updated_at = NOW()", nextAttemptAt).
+		Returning("id, config_id, status")).
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "releasing claimed delivery")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "reading released delivery rows affected")
 	}
-	result, err := s.db.NewRaw(`
+	if affected != 1 {
+		return storage.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// RecoverStaleDeliveries hands the deliveries whose claim lease expired back
+// to the queue: their worker stopped heartbeating, so it is gone or stuck.
+// Deliveries of inactive configs are cancelled instead.
+func (s Store) RecoverStaleDeliveries(ctx context.Context) (int64, error) {
+	result, err := withDeliveryEvents(s.db, s.db.NewRaw(`
 		WITH stale AS (
 			SELECT d.id, c.active AND c.deleted_at IS NULL AS config_active
 			FROM deliveries d
 			JOIN configs c ON c.id = d.config_id
 			WHERE d.status = ?
-			  AND d.claimed_at < ?
-			ORDER BY d.claimed_at, d.id
+			  AND d.lease_expires_at < NOW()
+			ORDER BY d.lease_expires_at, d.id
 			FOR UPDATE OF d, c SKIP LOCKED
 		)
 		UPDATE deliveries d
 		SET status = CASE WHEN stale.config_active THEN ? ELSE ? END,
 			claimed_at = NULL,
+			claimed_by = NULL,
+			lease_expires_at = NULL,
 			next_attempt_at = CASE WHEN stale.config_active THEN NOW() ELSE NULL END,
 			updated_at = NOW()
 		FROM stale
 		WHERE d.id = stale.id
-	`, webhooks.StatusDeliveryDelivering, time.Now().UTC().Add(-staleDuration),
-		webhooks.StatusDeliveryPending, webhooks.StatusDeliveryCancelled).Exec(ctx)
+		RETURNING d.id, d.config_id, d.status
+	`, webhooks.StatusDeliveryDelivering,
+		webhooks.StatusDeliveryPending, webhooks.StatusDeliveryCancelled)).Exec(ctx)
 	if err != nil {
 		return 0, errors.Wrap(err, "recovering stale deliveries")
 	}
@@ -197,14 +419,55 @@ func (s Store) RecoverStaleDeliveries(ctx context.Context, staleDuration time.Du
 	return count, errors.Wrap(err, "reading recovered delivery count")
 }
 
-func (s Store) CountPendingDeliveries(ctx context.Context) (int64, error) {
-	var count int64
+// CountPendingDeliveries counts pending deliveries per priority, each count
+// capped at 1000000.
+func (s Store) CountPendingDeliveries(ctx context.Context) (map[string]int64, error) {
+	rows := []struct {
+		Priority string `bun:"priority"`
+		Count    int64  `bun:"count"`
+	}{}
 	err := s.db.NewRaw(`
-		SELECT COUNT(*) FROM (
-			SELECT 1 FROM deliveries WHERE status = ? LIMIT 1000000
-		) pending
-	`, webhooks.StatusDeliveryPending).Scan(ctx, &count)
-	return count, errors.Wrap(err, "counting pending deliveries")
+		SELECT lanes.priority, (
+			SELECT COUNT(*) FROM (
+				SELECT 1 FROM deliveries WHERE status = ? AND priority = lanes.priority LIMIT 1000000
+			) pending
+		) AS count
+		FROM unnest(?::varchar[]) AS lanes(priority)
+	`, webhooks.StatusDeliveryPending, pgdialect.Array(webhooks.Priorities)).Scan(ctx, &rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting pending deliveries")
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Priority] = row.Count
+	}
+	return counts, nil
+}
+
+// OldestDueDeliveryAge returns how long the oldest claimable pending delivery
+// has been due, or zero when none is. Deliveries of configs that cannot be
+// claimed from, paused or in maintenance, are left out like in
+// claimDeliveries. Each config and lane reads its minimum from the first
+// entry of idx_deliveries_pending_config_priority_due.
+func (s Store) OldestDueDeliveryAge(ctx context.Context) (time.Duration, error) {
+	var seconds float64
+	err := s.db.NewRaw(`
+		SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(oldest.next_attempt_at)), 0)
+		FROM configs c
+		CROSS JOIN unnest(?::varchar[]) AS lanes(priority)
+		CROSS JOIN LATERAL (
+			SELECT d.next_attempt_at
+			FROM deliveries d
+			WHERE d.config_id = c.id AND d.status = ? AND d.priority = lanes.priority AND d.next_attempt_at <= NOW()
+			ORDER BY d.next_attempt_at
+			LIMIT 1
+		) oldest
+		WHERE `+dispatchableConfig+`
+	`, pgdialect.Array(webhooks.Priorities), webhooks.StatusDeliveryPending).Scan(ctx, &seconds)
+	if err != nil {
+		return 0, errors.Wrap(err, "reading oldest due delivery")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
 }
 
 func (s Store) FindDeliveries(ctx context.Context, filter webhooks.DeliveryFilter) (webhooks.DeliveryPage, error) {
@@ -223,6 +486,24 @@ func (s Store) FindDeliveries(ctx context.Context, filter webhooks.DeliveryFilte
 	if filter.Status != "" {
 		q = q.Where("status = ?", filter.Status)
 	}
+	if filter.Scheduled {
+		q = q.Where("status = ? AND deliver_at > NOW()", webhooks.StatusDeliveryPending)
+	}
+	if filter.EventID != "" {
+		q = q.Where("event_id = ?", filter.EventID)
+	}
+	if filter.IdempotencyKey != "" {
+		q = q.Where("idempotency_key = ?", filter.IdempotencyKey)
+	}
+	if filter.EventType != "" {
+		q = q.Where("event_type = ?", filter.EventType)
+	}
+	if filter.LastStatusCode != nil {
+		q = q.Where("last_status_code = ?", *filter.LastStatusCode)
+	}
+	if filter.PayloadContains != "" {
+		q = q.Where("payload::jsonb @> ?::jsonb", filter.PayloadContains)
+	}
 	if !filter.CreatedAfter.IsZero() {
 		q = q.Where("created_at >= ?", filter.CreatedAfter)
 	}
@@ -360,13 +641,15 @@ func (s Store) ReplayDelivery(ctx context.Context, id, idempotencyKey string) (w
 	if err := tx.NewSelect().Model(&config).
 		Where("id = ?", delivery.ConfigID).
 		Where("active = true").
-		Where("deleted_at IS NULL").Scan(ctx); err != nil {
+		Where("deleted_at IS NULL").
+		Where("draining_since IS NULL").Scan(ctx); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return webhooks.Delivery{}, false, storage.ErrDeliveryNotReplayable
 		}
 		return webhooks.Delivery{}, false, errors.Wrap(err, "selecting replay config")
 	}
 	now := time.Now().UTC()
+	previousStatus := delivery.Status
 	switch delivery.Status {
 	case webhooks.StatusDeliveryFailed:
 		delivery.Status = webhooks.StatusDeliveryPending
@@ -381,17 +664,122 @@ func (s Store) ReplayDelivery(ctx context.Context, id, idempotencyKey string) (w
 		return webhooks.Delivery{}, false, storage.ErrDeliveryNotReplayable
 	}
 	delivery.UpdatedAt = now
-	if _, err := tx.NewUpdate().Model(&delivery).
+	update := tx.NewUpdate().Model(&delivery).
 		Column("status", "replay_generation", "attempt_count", "cycle_started_at", "next_attempt_at", "claimed_at", "updated_at").
-		WherePK().Exec(ctx); err != nil {
+		WherePK()
+	if delivery.Status != previousStatus {
+		_, err = withDeliveryEvents(tx, update.Returning("id, config_id, status")).Exec(ctx)
+	} else {
+		_, err = update.Exec(ctx)
+	}
+	if err != nil {
 		return webhooks.Delivery{}, false, errors.Wrap(err, "replaying delivery")
 	}
+	if err := notifyDeliveriesDue(ctx, tx); err != nil {
+		return webhooks.Delivery{}, false, err
+	}
 	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, delivery); err != nil {
 		return webhooks.Delivery{}, false, err
 	}
 	return delivery, true, errors.Wrap(tx.Commit(), "committing delivery replay")
 }
 
+// replayTarget locks the config that replayed deliveries are sent to.
+func replayTarget(ctx context.Context, tx bun.Tx, id string) (webhooks.Config, error) {
+	config := webhooks.Config{}
+	err := tx.NewSelect().Model(&config).Where("id = ?", id).Where("deleted_at IS NULL").For("SHARE").Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhooks.Config{}, storage.ErrConfigNotFound
+	}
+	if err != nil {
+		return webhooks.Config{}, errors.Wrap(err, "selecting replay target config")
+	}
+	if !config.Active || config.DrainingSince != nil {
+		return webhooks.Config{}, fmt.Errorf("%w: target config is inactive", storage.ErrDeliveryNotReplayable)
+	}
+	return config, nil
+}
+
+// insertReplayCopies creates a pending delivery to target for each source,
+// linked to it through ReplayedFrom when linked is set. An event the target
+// already has a delivery for is skipped.
+func insertReplayCopies(ctx context.Context, tx bun.Tx, target webhooks.Config, sources []webhooks.Delivery, now time.Time, linked bool) ([]webhooks.Delivery, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	copies := make([]webhooks.Delivery, 0, len(sources))
+	for _, source := range sources {
+		nextAttemptAt := now
+		delivery := webhooks.Delivery{
+			ID: uuid.NewString(), EventID: source.EventID, IdempotencyKey: source.IdempotencyKey,
+			ConfigID: target.ID, EventType: source.EventType, Payload: source.Payload,
+			Status: webhooks.StatusDeliveryPending, Priority: target.PriorityFor(source.EventType),
+			NextAttemptAt: &nextAttemptAt, CreatedAt: now, UpdatedAt: now,
+		}
+		if linked {
+			delivery.ReplayedFrom = source.ID
+		}
+		copies = append(copies, delivery)
+	}
+	inserted := []webhooks.Delivery{}
+	if err := withDeliveryEvents(tx, tx.NewInsert().Model(&copies).
+		On("CONFLICT (event_id, config_id) DO NOTHING").
+		Returning("*")).Scan(ctx, &inserted); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, "inserting replayed deliveries")
+	}
+	return inserted, nil
+}
+
+func (s Store) ReplayDeliveryToConfig(ctx context.Context, id, targetConfigID, idempotencyKey string) (webhooks.Delivery, bool, error) {
+	hash, err := requestHash("delivery-to-config", struct {
+		ID, TargetConfigID string
+	}{id, targetConfigID})
+	if err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "beginning replay transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if cached, err := replayCached[webhooks.Delivery](ctx, tx, idempotencyKey, hash); err != nil {
+		return webhooks.Delivery{}, false, err
+	} else if cached != nil {
+		return *cached, false, errors.Wrap(tx.Commit(), "committing cached replay")
+	}
+
+	source := webhooks.Delivery{}
+	err = tx.NewSelect().Model(&source).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "selecting delivery for replay")
+	}
+	if source.Status != webhooks.StatusDeliveryFailed && source.Status != webhooks.StatusDeliveryPending {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotReplayable
+	}
+	target, err := replayTarget(ctx, tx, targetConfigID)
+	if err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	inserted, err := insertReplayCopies(ctx, tx, target, []webhooks.Delivery{source}, time.Now().UTC(), true)
+	if err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	if len(inserted) == 0 {
+		return webhooks.Delivery{}, false, fmt.Errorf("%w: target config already has a delivery for event %s",
+			storage.ErrDeliveryNotReplayable, source.EventID)
+	}
+	if err := notifyDeliveriesDue(ctx, tx); err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, inserted[0]); err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	return inserted[0], true, errors.Wrap(tx.Commit(), "committing delivery replay")
+}
+
 func (s Store) ReplayDeliveries(ctx context.Context, request webhooks.ReplayDeliveriesRequest, idempotencyKey string) (webhooks.ReplayDeliveriesResult, bool, error) {
 	hash, err := requestHash("deliveries", request)
 	if err != nil {
@@ -417,17 +805,29 @@ func (s Store) ReplayDeliveries(ctx context.Context, request webhooks.ReplayDeli
 	if request.PageSize <= 0 || request.PageSize > maxReplayPageSize {
 		request.PageSize = maxReplayPageSize
 	}
+	var target webhooks.Config
+	if request.TargetConfigID != "" {
+		if target, err = replayTarget(ctx, tx, request.TargetConfigID); err != nil {
+			return webhooks.ReplayDeliveriesResult{}, false, err
+		}
+	}
 	candidates := []webhooks.Delivery{}
 	q := tx.NewSelect().Model(&candidates).ModelTableExpr("deliveries AS d").
 		ColumnExpr("d.*").
-		Join("JOIN configs c ON c.id = d.config_id").
 		Where("d.created_at >= ?", request.CreatedAtFrom).
 		Where("d.created_at <= ?", request.CreatedAtTo).
 		Where("d.status IN (?)", bun.List(request.Statuses)).
-		Where("c.active = true AND c.deleted_at IS NULL").
 		OrderExpr("d.created_at ASC, d.id ASC").
-		Limit(request.PageSize + 1).
-		For("UPDATE OF d")
+		Limit(request.PageSize + 1)
+	if request.TargetConfigID == "" {
+		// In-place replays need a live source config; replays to another
+		// config leave the originals untouched.
+		q = q.Join("JOIN configs c ON c.id = d.config_id").
+			Where("c.active = true AND c.deleted_at IS NULL AND c.draining_since IS NULL").
+			For("UPDATE OF d")
+	} else {
+		q = q.Where("d.config_id <> ?", request.TargetConfigID)
+	}
 	if len(request.ConfigIDs) > 0 {
 		q = q.Where("d.config_id IN (?)", bun.List(request.ConfigIDs))
 	}
@@ -443,6 +843,136 @@ func (s Store) ReplayDeliveries(ctx context.Context, request webhooks.ReplayDeli
 		result.HasMore = true
 		candidates = candidates[:request.PageSize]
 	}
+	now := time.Now().UTC()
+	if request.TargetConfigID != "" {
+		inserted, err := insertReplayCopies(ctx, tx, target, candidates, now, true)
+		if err != nil {
+			return webhooks.ReplayDeliveriesResult{}, false, err
+		}
+		result.Replayed = len(inserted)
+		result.Skipped = len(candidates) - result.Replayed
+	} else if err := replayInPlace(ctx, tx, candidates, now, &result); err != nil {
+		return webhooks.ReplayDeliveriesResult{}, false, err
+	}
+	if result.HasMore && len(candidates) > 0 {
+		last := candidates[len(candidates)-1]
+		result.NextCursor = &webhooks.DeliveryCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		result.NextCursorToken, err = webhooks.EncodeReplayDeliveryCursor(webhooks.ReplayDeliveryCursor{
+			Position: *result.NextCursor, CreatedAtFrom: request.CreatedAtFrom, CreatedAtTo: request.CreatedAtTo,
+			Statuses: request.Statuses, ConfigIDs: request.ConfigIDs, TargetConfigID: request.TargetConfigID,
+		})
+		if err != nil {
+			return webhooks.ReplayDeliveriesResult{}, false, errors.Wrap(err, "encoding bulk replay cursor")
+		}
+	}
+	if result.Replayed+result.Expedited > 0 {
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return webhooks.ReplayDeliveriesResult{}, false, err
+		}
+	}
+	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, result); err != nil {
+		return webhooks.ReplayDeliveriesResult{}, false, err
+	}
+	return result, true, errors.Wrap(tx.Commit(), "committing bulk replay")
+}
+
+// BackfillConfig enqueues, for configID, the events of the other configs that
+// match its event types. Each event is read from its first delivery.
+func (s Store) BackfillConfig(ctx context.Context, configID string, request webhooks.BackfillConfigRequest, idempotencyKey string) (webhooks.BackfillConfigResult, bool, error) {
+	hash, err := requestHash("backfill", struct {
+		ConfigID string
+		Request  webhooks.BackfillConfigRequest
+	}{configID, request})
+	if err != nil {
+		return webhooks.BackfillConfigResult{}, false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.BackfillConfigResult{}, false, errors.Wrap(err, "beginning backfill transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if cached, err := replayCached[webhooks.BackfillConfigResult](ctx, tx, idempotencyKey, hash); err != nil {
+		return webhooks.BackfillConfigResult{}, false, err
+	} else if cached != nil {
+		return *cached, false, errors.Wrap(tx.Commit(), "committing cached backfill")
+	}
+
+	config, err := replayTarget(ctx, tx, configID)
+	if err != nil {
+		return webhooks.BackfillConfigResult{}, false, err
+	}
+	if request.CreatedAtTo.IsZero() {
+		request.CreatedAtTo = time.Now().UTC()
+	}
+	if request.PageSize <= 0 || request.PageSize > maxReplayPageSize {
+		request.PageSize = maxReplayPageSize
+	}
+	firstDeliveries := tx.NewSelect().Model((*webhooks.Delivery)(nil)).
+		DistinctOn("delivery.event_id").
+		Column("id", "event_id", "idempotency_key", "event_type", "payload", "created_at").
+		Where("delivery.created_at >= ?", request.CreatedAtFrom).
+		Where("delivery.created_at <= ?", request.CreatedAtTo).
+		Where("delivery.event_type IN (?)", bun.List(config.EventTypes)).
+		Where("delivery.config_id <> ?", config.ID).
+		OrderExpr("delivery.event_id, delivery.created_at, delivery.id")
+	if request.Cursor != nil {
+		// Only the rest of the window is sorted. Events whose first delivery
+		// is behind the cursor were on a previous page.
+		firstDeliveries = firstDeliveries.
+			Where("(delivery.created_at, delivery.id) > (?, ?)", request.Cursor.CreatedAt, request.Cursor.ID).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM deliveries earlier
+				WHERE earlier.event_id = delivery.event_id
+				  AND earlier.config_id <> ?
+				  AND earlier.created_at >= ?
+				  AND (earlier.created_at, earlier.id) <= (?, ?)
+			)`, config.ID, request.CreatedAtFrom, request.Cursor.CreatedAt, request.Cursor.ID)
+	}
+	events := []webhooks.Delivery{}
+	q := tx.NewSelect().With("events", firstDeliveries).
+		Model(&events).ModelTableExpr("events AS delivery").ColumnExpr("delivery.*").
+		OrderExpr("delivery.created_at ASC, delivery.id ASC").
+		Limit(request.PageSize + 1)
+	if err := q.Scan(ctx); err != nil {
+		return webhooks.BackfillConfigResult{}, false, errors.Wrap(err, "selecting events for backfill")
+	}
+
+	result := webhooks.BackfillConfigResult{CreatedAtTo: request.CreatedAtTo}
+	if len(events) > request.PageSize {
+		result.HasMore = true
+		events = events[:request.PageSize]
+	}
+	inserted, err := insertReplayCopies(ctx, tx, config, events, time.Now().UTC(), false)
+	if err != nil {
+		return webhooks.BackfillConfigResult{}, false, err
+	}
+	result.Enqueued = len(inserted)
+	result.Skipped = len(events) - result.Enqueued
+	if result.HasMore {
+		last := events[len(events)-1]
+		result.NextCursor = &webhooks.DeliveryCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		result.NextCursorToken, err = webhooks.EncodeBackfillCursor(webhooks.BackfillCursor{
+			Position: *result.NextCursor, ConfigID: config.ID,
+			CreatedAtFrom: request.CreatedAtFrom, CreatedAtTo: request.CreatedAtTo,
+		})
+		if err != nil {
+			return webhooks.BackfillConfigResult{}, false, errors.Wrap(err, "encoding backfill cursor")
+		}
+	}
+	if result.Enqueued > 0 {
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return webhooks.BackfillConfigResult{}, false, err
+		}
+	}
+	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, result); err != nil {
+		return webhooks.BackfillConfigResult{}, false, err
+	}
+	return result, true, errors.Wrap(tx.Commit(), "committing backfill")
+}
+
+// replayInPlace re-queues failed candidates with a fresh retry budget and
+// expedites pending ones.
+func replayInPlace(ctx context.Context, tx bun.Tx, candidates []webhooks.Delivery, now time.Time, result *webhooks.ReplayDeliveriesResult) error {
 	failedIDs := make([]string, 0, len(candidates))
 	pendingIDs := make([]string, 0, len(candidates))
 	for _, delivery := range candidates {
@@ -455,15 +985,15 @@ func (s Store) ReplayDeliveries(ctx context.Context, request webhooks.ReplayDeli
 			result.Skipped++
 		}
 	}
-	now := time.Now().UTC()
 	if len(failedIDs) > 0 {
-		res, err := tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
+		res, err := withDeliveryEvents(tx, tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
 			Where("id IN (?)", bun.List(failedIDs)).Where("status = ?", webhooks.StatusDeliveryFailed).
 			Set("status = ?", webhooks.StatusDeliveryPending).
 			Set("replay_generation = replay_generation + 1, attempt_count = 0, cycle_started_at = NULL, claimed_at = NULL").
-			Set("next_attempt_at = ?, updated_at = ?", now, now).Exec(ctx)
+			Set("next_attempt_at = ?, updated_at = ?", now, now).
+			Returning("id, config_id, status")).Exec(ctx)
 		if err != nil {
-			return webhooks.ReplayDeliveriesResult{}, false, errors.Wrap(err, "replaying failed deliveries")
+			return errors.Wrap(err, "replaying failed deliveries")
 		}
 		affected, _ := res.RowsAffected()
 		result.Replayed = int(affected)
@@ -474,27 +1004,210 @@ func (s Store) ReplayDeliveries(ctx context.Context, request webhooks.ReplayDeli
 			Where("id IN (?)", bun.List(pendingIDs)).Where("status = ?", webhooks.StatusDeliveryPending).
 			Set("next_attempt_at = ?, updated_at = ?", now, now).Exec(ctx)
 		if err != nil {
-			return webhooks.ReplayDeliveriesResult{}, false, errors.Wrap(err, "expediting pending deliveries")
+			return errors.Wrap(err, "expediting pending deliveries")
 		}
 		affected, _ := res.RowsAffected()
 		result.Expedited = int(affected)
 		result.Skipped += len(pendingIDs) - result.Expedited
 	}
-	if result.HasMore && len(candidates) > 0 {
-		last := candidates[len(candidates)-1]
-		result.NextCursor = &webhooks.DeliveryCursor{CreatedAt: last.CreatedAt, ID: last.ID}
-		result.NextCursorToken, err = webhooks.EncodeReplayDeliveryCursor(webhooks.ReplayDeliveryCursor{
-			Position: *result.NextCursor, CreatedAtFrom: request.CreatedAtFrom, CreatedAtTo: request.CreatedAtTo,
-			Statuses: request.Statuses, ConfigIDs: request.ConfigIDs,
-		})
+	return nil
+}
+
+func (s Store) RescheduleDelivery(ctx context.Context, id string, request webhooks.RescheduleDeliveryRequest, idempotencyKey string) (webhooks.Delivery, bool, error) {
+	hash, err := requestHash("reschedule-delivery", struct {
+		ID      string
+		Request webhooks.RescheduleDeliveryRequest
+	}{id, request})
+	if err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "beginning reschedule transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if cached, err := replayCached[webhooks.Delivery](ctx, tx, idempotencyKey, hash); err != nil {
+		return webhooks.Delivery{}, false, err
+	} else if cached != nil {
+		return *cached, false, errors.Wrap(tx.Commit(), "committing cached reschedule")
+	}
+
+	delivery := webhooks.Delivery{}
+	err = tx.NewSelect().Model(&delivery).Where("id = ?", id).For("UPDATE").Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "selecting delivery for reschedule")
+	}
+	if delivery.Status != webhooks.StatusDeliveryPending {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotReschedulable
+	}
+	exists, err := tx.NewSelect().Model((*webhooks.Config)(nil)).
+		Where("id = ?", delivery.ConfigID).
+		Where("active = true AND deleted_at IS NULL AND draining_since IS NULL").Exists(ctx)
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "selecting reschedule config")
+	}
+	if !exists {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotReschedulable
+	}
+	now := time.Now().UTC()
+	nextAttemptAt := now
+	if request.NextAttemptAt != nil && request.NextAttemptAt.After(now) {
+		nextAttemptAt = request.NextAttemptAt.UTC()
+	}
+	delivery.NextAttemptAt = &nextAttemptAt
+	if request.ResetRetryCycle {
+		// Attempt numbers restart, so they must belong to a new generation.
+		delivery.ReplayGeneration++
+		delivery.AttemptCount = 0
+		delivery.CycleStartedAt = nil
+	}
+	delivery.UpdatedAt = now
+	update := tx.NewUpdate().Model(&delivery).
+		Column("replay_generation", "attempt_count", "cycle_started_at", "next_attempt_at", "updated_at").
+		WherePK()
+	if request.ResetRetryCycle {
+		_, err = withDeliveryEvents(tx, update.Returning("id, config_id, status")).Exec(ctx)
+	} else {
+		_, err = update.Exec(ctx)
+	}
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "rescheduling delivery")
+	}
+	if nextAttemptAt.Equal(now) {
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return webhooks.Delivery{}, false, err
+		}
+	}
+	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, delivery); err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	return delivery, true, errors.Wrap(tx.Commit(), "committing delivery reschedule")
+}
+
+var cancellableDeliveryStatuses = []string{webhooks.StatusDeliveryPending, webhooks.StatusDeliveryDelivering}
+
+func (s Store) CancelOneDelivery(ctx context.Context, id string, cancellation webhooks.DeliveryCancellation, idempotencyKey string) (webhooks.Delivery, bool, error) {
+	hash, err := requestHash("cancel-delivery", struct {
+		ID, By, Reason string
+	}{id, cancellation.By, cancellation.Reason})
+	if err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "beginning cancellation transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if cached, err := replayCached[webhooks.Delivery](ctx, tx, idempotencyKey, hash); err != nil {
+		return webhooks.Delivery{}, false, err
+	} else if cached != nil {
+		return *cached, false, errors.Wrap(tx.Commit(), "committing cached cancellation")
+	}
+
+	delivery := webhooks.Delivery{}
+	err = tx.NewSelect().Model(&delivery).Where("id = ?", id).For("UPDATE").Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "selecting delivery for cancellation")
+	}
+	if !slices.Contains(cancellableDeliveryStatuses, delivery.Status) {
+		return webhooks.Delivery{}, false, storage.ErrDeliveryNotCancellable
+	}
+	delivery.Status = webhooks.StatusDeliveryCancelled
+	delivery.NextAttemptAt = nil
+	delivery.ClaimedAt = nil
+	delivery.ClaimedBy = ""
+	delivery.LeaseExpiresAt = nil
+	delivery.CancelledBy = cancellation.By
+	delivery.CancellationReason = cancellation.Reason
+	delivery.UpdatedAt = time.Now().UTC()
+	if _, err := withDeliveryEvents(tx, tx.NewUpdate().Model(&delivery).
+		Column("status", "next_attempt_at", "claimed_at", "claimed_by", "lease_expires_at",
+			"cancelled_by", "cancellation_reason", "updated_at").
+		WherePK().Returning("id, config_id, status")).Exec(ctx); err != nil {
+		return webhooks.Delivery{}, false, errors.Wrap(err, "cancelling delivery")
+	}
+	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, delivery); err != nil {
+		return webhooks.Delivery{}, false, err
+	}
+	return delivery, true, errors.Wrap(tx.Commit(), "committing delivery cancellation")
+}
+
+func (s Store) CancelDeliveries(ctx context.Context, request webhooks.CancelDeliveriesRequest, cancellation webhooks.DeliveryCancellation, idempotencyKey string) (webhooks.CancelDeliveriesResult, bool, error) {
+	hash, err := requestHash("cancel-deliveries", struct {
+		Request webhooks.CancelDeliveriesRequest
+		By      string
+	}{request, cancellation.By})
+	if err != nil {
+		return webhooks.CancelDeliveriesResult{}, false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.CancelDeliveriesResult{}, false, errors.Wrap(err, "beginning bulk cancellation transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if cached, err := replayCached[webhooks.CancelDeliveriesResult](ctx, tx, idempotencyKey, hash); err != nil {
+		return webhooks.CancelDeliveriesResult{}, false, err
+	} else if cached != nil {
+		return *cached, false, errors.Wrap(tx.Commit(), "committing cached bulk cancellation")
+	}
+
+	if request.CreatedAtTo.IsZero() {
+		request.CreatedAtTo = time.Now().UTC()
+	}
+	if request.PageSize <= 0 || request.PageSize > maxReplayPageSize {
+		request.PageSize = maxReplayPageSize
+	}
+	ids := []string{}
+	q := tx.NewSelect().Model((*webhooks.Delivery)(nil)).Column("id").
+		Where("created_at >= ?", request.CreatedAtFrom).
+		Where("created_at <= ?", request.CreatedAtTo).
+		Where("status IN (?)", bun.List(cancellableDeliveryStatuses)).
+		OrderExpr("created_at ASC, id ASC").
+		Limit(request.PageSize + 1).
+		For("UPDATE")
+	if len(request.ConfigIDs) > 0 {
+		q = q.Where("config_id IN (?)", bun.List(request.ConfigIDs))
+	}
+	if len(request.EventTypes) > 0 {
+		q = q.Where("event_type IN (?)", bun.List(request.EventTypes))
+	}
+	if request.ScheduledOnly {
+		q = q.Where("status = ? AND deliver_at > NOW()", webhooks.StatusDeliveryPending)
+	}
+	if err := q.Scan(ctx, &ids); err != nil {
+		return webhooks.CancelDeliveriesResult{}, false, errors.Wrap(err, "selecting deliveries for bulk cancellation")
+	}
+
+	result := webhooks.CancelDeliveriesResult{CreatedAtTo: request.CreatedAtTo}
+	if len(ids) > request.PageSize {
+		result.HasMore = true
+		ids = ids[:request.PageSize]
+	}
+	if len(ids) > 0 {
+		res, err := withDeliveryEvents(tx, tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
+			Where("id IN (?)", bun.List(ids)).
+			Set("status = ?", webhooks.StatusDeliveryCancelled).
+			Set("claimed_at = NULL, claimed_by = NULL, lease_expires_at = NULL").
+			Set("next_attempt_at = NULL, updated_at = NOW()").
+			Set("cancelled_by = NULLIF(?, ''), cancellation_reason = NULLIF(?, '')", cancellation.By, cancellation.Reason).
+			Returning("id, config_id, status")).
+			Exec(ctx)
 		if err != nil {
-			return webhooks.ReplayDeliveriesResult{}, false, errors.Wrap(err, "encoding bulk replay cursor")
+			return webhooks.CancelDeliveriesResult{}, false, errors.Wrap(err, "cancelling deliveries")
 		}
+		affected, _ := res.RowsAffected()
+		result.Cancelled = int(affected)
 	}
 	if err := storeReplayResponse(ctx, tx, idempotencyKey, hash, result); err != nil {
-		return webhooks.ReplayDeliveriesResult{}, false, err
+		return webhooks.CancelDeliveriesResult{}, false, err
 	}
-	return result, true, errors.Wrap(tx.Commit(), "committing bulk replay")
+	return result, true, errors.Wrap(tx.Commit(), "committing bulk cancellation")
 }
 
 func (s Store) PurgeFinishedDeliveries(ctx context.Context, successOlderThan, failedOlderThan time.Duration, batchSize int) (int64, error) {
@@ -532,6 +1245,12 @@ func (s Store) PurgeFinishedDeliveries(ctx context.Context, successOlderThan, fa
 	if err != nil {
 		return total, errors.Wrap(err, "purging replay requests")
 	}
+	// The activity feed only has to cover Last-Event-ID resumption.
+	_, err = s.db.NewDelete().Model((*webhooks.DeliveryEvent)(nil)).
+		Where("created_at < ?", time.Now().UTC().Add(-24*time.Hour)).Exec(ctx)
+	if err != nil {
+		return total, errors.Wrap(err, "purging delivery events")
+	}
 	_, err = s.db.NewRaw(`
 		DELETE FROM configs
 		WHERE id IN (
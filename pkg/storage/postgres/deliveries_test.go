@@ -3,6 +3,7 @@ package postgres_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -15,6 +16,8 @@ import (
 	"github.com/uptrace/bun"
 )
 
+var testLease = webhooks.ClaimLease{WorkerID: "test-worker", Duration: time.Minute}
+
 func insertDeliveryConfig(t *testing.T, store storage.Store) webhooks.Config {
 	t.Helper()
 	config, err := store.InsertOneConfig(context.Background(), webhooks.ConfigUser{
@@ -47,7 +50,7 @@ func TestDurableDeliveryLifecycleAndBrokerDeduplication(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, page.Data, 1, "the event/config uniqueness constraint must absorb broker redelivery")
 
-	claimed, err := store.ClaimDeliveries(ctx, 10)
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
 	require.NoError(t, err)
 	require.Len(t, claimed, 1)
 	require.Equal(t, webhooks.StatusDeliveryDelivering, claimed[0].Status)
@@ -67,9 +70,9 @@ func TestDurableDeliveryLifecycleAndBrokerDeduplication(t *testing.T) {
 		Endpoint: config.Endpoint, Outcome: webhooks.OutcomeDeliverySucceeded,
 		StatusCode: 200, DurationMillis: &durationMillis, CreatedAt: completedAt,
 	}
-	finalStatus, err := store.CompleteDelivery(ctx, delivery, attempt)
+	completion, err := store.CompleteDelivery(ctx, delivery, attempt)
 	require.NoError(t, err)
-	require.Equal(t, webhooks.StatusDeliverySucceeded, finalStatus)
+	require.Equal(t, webhooks.StatusDeliverySucceeded, completion.Status)
 
 	stored, err := store.GetDelivery(ctx, delivery.ID)
 	require.NoError(t, err)
@@ -97,22 +100,63 @@ func TestEnqueueEventTargetsOnlyActiveConfigsAndDeduplicatesBrokerRedelivery(t *
 	require.NoError(t, err)
 
 	createdAt := time.Now().UTC().Truncate(time.Microsecond)
-	require.NoError(t, store.EnqueueEvent(ctx, "event-enqueue", "event-key", "test.event", `{"type":"test.event"}`, createdAt))
-	require.NoError(t, store.EnqueueEvent(ctx, "event-enqueue", "event-key", "test.event", `{"type":"test.event"}`, createdAt))
+	first, err := store.EnqueueEvent(ctx, "event-enqueue", "event-key", "test.event", `{"type":"test.event"}`, createdAt, time.Time{})
+	require.NoError(t, err)
+	redelivered, err := store.EnqueueEvent(ctx, "event-enqueue", "event-key", "test.event", `{"type":"test.event"}`, createdAt, time.Time{})
+	require.NoError(t, err)
 	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{PageSize: 10})
 	require.NoError(t, err)
 	require.Len(t, page.Data, 1)
 	require.Equal(t, activeConfig.ID, page.Data[0].ConfigID)
 	require.Equal(t, "event-key", page.Data[0].IdempotencyKey)
+	require.Equal(t, []webhooks.EventMatch{{ConfigID: activeConfig.ID, DeliveryID: page.Data[0].ID}}, first.Matches)
+	require.Equal(t, []webhooks.EventMatch{{ConfigID: activeConfig.ID, DeliveryID: page.Data[0].ID, Duplicate: true}}, redelivered.Matches)
 
-	require.NoError(t, store.DeleteOneConfig(ctx, activeConfig.ID))
-	require.NoError(t, store.EnqueueEvent(ctx, "event-after-delete", "event-key-2", "test.event", `{"type":"test.event"}`, createdAt))
+	require.NoError(t, store.DeleteOneConfig(ctx, activeConfig.ID, webhooks.DeleteModeCancel))
+	_, err = store.EnqueueEvent(ctx, "event-after-delete", "event-key-2", "test.event", `{"type":"test.event"}`, createdAt, time.Time{})
+	require.NoError(t, err)
 	page, err = store.FindDeliveries(ctx, webhooks.DeliveryFilter{PageSize: 10})
 	require.NoError(t, err)
 	require.Len(t, page.Data, 1, "deleted and inactive configs must not receive new deliveries")
 	require.Equal(t, webhooks.StatusDeliveryCancelled, page.Data[0].Status)
 }
 
+func TestScheduledDeliveriesWaitAndCanBeCancelled(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	insertDeliveryConfig(t, store)
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	deliverAt := createdAt.Add(time.Hour)
+	_, err := store.EnqueueEvent(ctx, "event-scheduled", "", "test.event", `{}`, createdAt, deliverAt)
+	require.NoError(t, err)
+	_, err = store.EnqueueEvent(ctx, "event-immediate", "", "test.event", `{}`, createdAt, createdAt.Add(-time.Minute))
+	require.NoError(t, err)
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "a scheduled delivery must not be claimed before deliverAt")
+	require.Equal(t, "event-immediate", claimed[0].EventID)
+	require.Nil(t, claimed[0].DeliverAt)
+
+	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{Scheduled: true, PageSize: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Data, 1)
+	require.Equal(t, "event-scheduled", page.Data[0].EventID)
+	require.WithinDuration(t, deliverAt, *page.Data[0].DeliverAt, time.Microsecond)
+	require.WithinDuration(t, deliverAt, *page.Data[0].NextAttemptAt, time.Microsecond)
+
+	result, _, err := store.CancelDeliveries(ctx, webhooks.CancelDeliveriesRequest{
+		CreatedAtFrom: createdAt.Add(-time.Minute), CreatedAtTo: createdAt.Add(time.Minute),
+		ScheduledOnly: true, PageSize: 10,
+	}, webhooks.DeliveryCancellation{By: "operator"}, "cancel-scheduled")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Cancelled)
+	page, err = store.FindDeliveries(ctx, webhooks.DeliveryFilter{Scheduled: true, PageSize: 10})
+	require.NoError(t, err)
+	require.Empty(t, page.Data)
+}
+
 func TestFailClaimedDeliveryEndsClaimWithoutCreatingAnAttempt(t *testing.T) {
 	store := newTestStore(t)
 	ctx := context.Background()
@@ -120,7 +164,7 @@ func TestFailClaimedDeliveryEndsClaimWithoutCreatingAnAttempt(t *testing.T) {
 	delivery := newDelivery(config.ID, "expired-delivery", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second))
 	delivery.CycleStartedAt = &delivery.CreatedAt
 	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
-	claimed, err := store.ClaimDeliveries(ctx, 1)
+	claimed, err := store.ClaimDeliveries(ctx, 1, testLease)
 	require.NoError(t, err)
 	require.Len(t, claimed, 1)
 	require.NotNil(t, claimed[0].ClaimedAt)
@@ -261,7 +305,7 @@ func TestSoftDeleteHidesConfigAndCancelsPendingDeliveries(t *testing.T) {
 	config := insertDeliveryConfig(t, store)
 	delivery := newDelivery(config.ID, "event-delete", webhooks.StatusDeliveryPending, time.Now().UTC())
 	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
-	require.NoError(t, store.DeleteOneConfig(ctx, config.ID))
+	require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModeCancel))
 
 	configs, err := store.FindManyConfigs(ctx, map[string]any{"id": config.ID})
 	require.NoError(t, err)
@@ -273,6 +317,200 @@ func TestSoftDeleteHidesConfigAndCancelsPendingDeliveries(t *testing.T) {
 	require.ErrorIs(t, err, storage.ErrDeliveryNotReplayable)
 }
 
+func TestDrainDeleteFinishesPendingDeliveriesBeforeDeleting(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	delivery := newDelivery(config.ID, "event-drain", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second))
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
+	require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModeDrain))
+
+	configs, err := store.FindManyConfigs(ctx, map[string]any{"id": config.ID})
+	require.NoError(t, err)
+	require.Len(t, configs, 1, "a draining config stays visible until its deliveries are finished")
+	require.NotNil(t, configs[0].DrainingSince)
+
+	_, err = store.EnqueueEvent(ctx, "event-after-drain", "", "test.event", `{}`, time.Now().UTC(), time.Time{})
+	require.NoError(t, err)
+	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{ConfigID: config.ID})
+	require.NoError(t, err)
+	require.Len(t, page.Data, 1, "draining configs must not receive new events")
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	completedAt := time.Now().UTC()
+	done := claimed[0]
+	done.Status = webhooks.StatusDeliverySucceeded
+	done.AttemptCount = 1
+	done.LastAttemptAt = &completedAt
+	done.NextAttemptAt = nil
+	_, err = store.CompleteDelivery(ctx, done, webhooks.DeliveryAttempt{
+		ID: uuid.NewString(), DeliveryID: done.ID, AttemptNumber: 1, Endpoint: config.Endpoint,
+		Outcome: webhooks.OutcomeDeliverySucceeded, StatusCode: 200, CreatedAt: completedAt,
+	})
+	require.NoError(t, err)
+
+	configs, err = store.FindManyConfigs(ctx, map[string]any{"id": config.ID})
+	require.NoError(t, err)
+	require.Empty(t, configs, "the config is deleted once drained")
+}
+
+func TestDrainDeleteIgnoresThePause(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	pausedBefore := insertDeliveryConfig(t, store)
+	pausedAfter := insertDeliveryConfig(t, store)
+	createdAt := time.Now().UTC().Add(-time.Second)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(pausedBefore.ID, "event-paused-before", webhooks.StatusDeliveryPending, createdAt),
+		newDelivery(pausedAfter.ID, "event-paused-after", webhooks.StatusDeliveryPending, createdAt),
+	}))
+
+	_, err := store.PauseOneConfig(ctx, pausedBefore.ID, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteOneConfig(ctx, pausedBefore.ID, webhooks.DeleteModeDrain))
+	require.NoError(t, store.DeleteOneConfig(ctx, pausedAfter.ID, webhooks.DeleteModeDrain))
+	_, err = store.PauseOneConfig(ctx, pausedAfter.ID, nil)
+	require.NoError(t, err)
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2, "a draining config is dispatched even while paused")
+	for _, delivery := range claimed {
+		completedAt := time.Now().UTC()
+		delivery.Status = webhooks.StatusDeliverySucceeded
+		delivery.AttemptCount = 1
+		delivery.LastAttemptAt = &completedAt
+		delivery.NextAttemptAt = nil
+		_, err = store.CompleteDelivery(ctx, delivery, webhooks.DeliveryAttempt{
+			ID: uuid.NewString(), DeliveryID: delivery.ID, AttemptNumber: 1, Endpoint: pausedBefore.Endpoint,
+			Outcome: webhooks.OutcomeDeliverySucceeded, StatusCode: 200, CreatedAt: completedAt,
+		})
+		require.NoError(t, err)
+	}
+
+	configs, err := store.FindManyConfigs(ctx, map[string]any{})
+	require.NoError(t, err)
+	require.Empty(t, configs, "both configs are deleted once drained")
+}
+
+func TestPurgeDeleteRemovesConfigAndDeliveries(t *testing.T) {
+	store, db := newTestStoreWithDB(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	delivery := newDelivery(config.ID, "event-purge", webhooks.StatusDeliverySucceeded, time.Now().UTC())
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
+	require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModePurge))
+
+	_, err := store.GetDelivery(ctx, delivery.ID)
+	require.ErrorIs(t, err, storage.ErrDeliveryNotFound)
+	count, err := db.NewSelect().Model((*webhooks.Config)(nil)).Where("id = ?", config.ID).Count(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count, "purged configs must not be kept as tombstones")
+}
+
+func TestPurgeDeleteErasesDeletedConfigs(t *testing.T) {
+	store, db := newTestStoreWithDB(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	delivery := newDelivery(config.ID, "event-tombstone", webhooks.StatusDeliveryPending, time.Now().UTC())
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
+	require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModeCancel))
+	require.ErrorIs(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModeCancel), storage.ErrConfigNotFound)
+
+	require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModePurge))
+	_, err := store.GetDelivery(ctx, delivery.ID)
+	require.ErrorIs(t, err, storage.ErrDeliveryNotFound)
+	count, err := db.NewSelect().Model((*webhooks.Config)(nil)).Where("id = ?", config.ID).Count(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	require.ErrorIs(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModePurge), storage.ErrConfigNotFound)
+}
+
+func TestPausedConfigBuffersDeliveriesUntilResume(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	paused, err := store.PauseOneConfig(ctx, config.ID, nil)
+	require.NoError(t, err)
+	require.NotNil(t, paused.PausedAt)
+
+	_, err = store.EnqueueEvent(ctx, "event-paused", "", "test.event", `{}`, time.Now().UTC().Add(-time.Second), time.Time{})
+	require.NoError(t, err)
+	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{ConfigID: config.ID})
+	require.NoError(t, err)
+	require.Len(t, page.Data, 1, "paused configs keep receiving events")
+	require.Equal(t, webhooks.StatusDeliveryPending, page.Data[0].Status)
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Empty(t, claimed, "deliveries of paused configs must not be dispatched")
+
+	resumed, err := store.ResumeOneConfig(ctx, config.ID)
+	require.NoError(t, err)
+	require.Nil(t, resumed.PausedAt)
+	claimed, err = store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	_, err = store.ResumeOneConfig(ctx, config.ID)
+	require.ErrorIs(t, err, storage.ErrConfigNotModified)
+}
+
+func TestPausedConfigResumesAtScheduledTime(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	resumeAt := time.Now().UTC().Add(time.Second)
+	_, err := store.PauseOneConfig(ctx, config.ID, &resumeAt)
+	require.NoError(t, err)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(config.ID, "event-scheduled-resume", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second)),
+	}))
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	require.Eventually(t, func() bool {
+		claimed, err = store.ClaimDeliveries(ctx, 10, testLease)
+		require.NoError(t, err)
+		return len(claimed) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+	count, err := store.ResumeDueConfigs(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+func TestClaimDeliveriesSkipsConfigsInMaintenance(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Minute)
+	config, err := store.InsertOneConfig(ctx, webhooks.ConfigUser{
+		Endpoint: "https://example.com/webhooks", Secret: webhooks.NewSecret(), EventTypes: []string{"test.event"},
+		MaintenanceWindows: []webhooks.MaintenanceWindow{{
+			Schedule: fmt.Sprintf("%d %d * * *", start.Minute(), start.Hour()), Duration: "1h",
+		}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, config.MaintenanceEndsAt)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(config.ID, "event-maintenance", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second)),
+	}))
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	withoutWindows := config.ConfigUser
+	withoutWindows.MaintenanceWindows = nil
+	require.NoError(t, store.UpdateOneConfig(ctx, config.ID, withoutWindows))
+	claimed, err = store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "removing the window makes deliveries claimable again")
+}
+
 func TestBackfillDeliveriesPreservesPreviousWebhookIdentity(t *testing.T) {
 	store, db := newTestStoreWithDB(t)
 	ctx := context.Background()
@@ -390,6 +628,65 @@ func TestBackfillCreatesNonReplayableTombstoneWithoutPreviousSecret(t *testing.T
 	require.ErrorIs(t, err, storage.ErrDeliveryNotReplayable)
 }
 
+func TestClaimDeliveriesWeighsPriorityLanes(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config, err := store.InsertOneConfig(ctx, webhooks.ConfigUser{
+		Endpoint: "https://example.com/webhooks", Secret: webhooks.NewSecret(),
+		EventTypes: []string{"test.event", "urgent.event"}, Priority: webhooks.PriorityLow,
+		EventTypePriorities: map[string]string{"urgent.event": webhooks.PriorityHigh},
+	})
+	require.NoError(t, err)
+	createdAt := time.Now().UTC().Add(-time.Minute)
+	for i := 0; i < 20; i++ {
+		_, err := store.EnqueueEvent(ctx, fmt.Sprintf("low-%d", i), "", "test.event", `{}`, createdAt, time.Time{})
+		require.NoError(t, err)
+		_, err = store.EnqueueEvent(ctx, fmt.Sprintf("high-%d", i), "", "urgent.event", `{}`, createdAt.Add(time.Second), time.Time{})
+		require.NoError(t, err)
+	}
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 10)
+	lanes := map[string]int{}
+	for _, delivery := range claimed {
+		require.Equal(t, config.ID, delivery.ConfigID)
+		lanes[delivery.Priority]++
+	}
+	require.Equal(t, map[string]int{webhooks.PriorityHigh: 9, webhooks.PriorityLow: 1}, lanes,
+		"newer high deliveries go first while the low lane keeps its share")
+
+	counts, err := store.CountPendingDeliveries(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{webhooks.PriorityHigh: 11, webhooks.PriorityNormal: 0, webhooks.PriorityLow: 19}, counts)
+}
+
+func TestClaimDeliveriesSharesBatchesBetweenConfigs(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	noisy := insertDeliveryConfig(t, store)
+	quiet := insertDeliveryConfig(t, store)
+	backlog := make([]webhooks.Delivery, 0, 30)
+	for i := 0; i < 30; i++ {
+		backlog = append(backlog, newDelivery(noisy.ID, fmt.Sprintf("backlog-%d", i), webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Hour)))
+	}
+	require.NoError(t, store.InsertDeliveries(ctx, backlog))
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(quiet.ID, "quiet-1", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second)),
+		newDelivery(quiet.ID, "quiet-2", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second)),
+	}))
+
+	claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 10, "capacity left by the quiet config goes to the backlog")
+	perConfig := map[string]int{}
+	for _, delivery := range claimed {
+		perConfig[delivery.ConfigID]++
+	}
+	require.Equal(t, 2, perConfig[quiet.ID], "an older backlog must not hold other configs back")
+	require.Equal(t, 8, perConfig[noisy.ID])
+}
+
 func TestConcurrentDeliveryClaimsNeverOverlap(t *testing.T) {
 	store := newTestStore(t)
 	ctx := context.Background()
@@ -407,7 +704,7 @@ func TestConcurrentDeliveryClaimsNeverOverlap(t *testing.T) {
 		wait.Add(1)
 		go func() {
 			defer wait.Done()
-			claimed, err := store.ClaimDeliveries(ctx, 10)
+			claimed, err := store.ClaimDeliveries(ctx, 10, testLease)
 			require.NoError(t, err)
 			for _, delivery := range claimed {
 				ids <- delivery.ID
@@ -449,7 +746,7 @@ func TestStaleWorkerCannotCompleteAReclaimedDelivery(t *testing.T) {
 	config := insertDeliveryConfig(t, store)
 	delivery := newDelivery(config.ID, "reclaimed-delivery", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second))
 	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
-	firstClaim, err := store.ClaimDeliveries(ctx, 1)
+	firstClaim, err := store.ClaimDeliveries(ctx, 1, testLease)
 	require.NoError(t, err)
 	require.Len(t, firstClaim, 1)
 	require.NotNil(t, firstClaim[0].CycleStartedAt)
@@ -457,12 +754,12 @@ func TestStaleWorkerCannotCompleteAReclaimedDelivery(t *testing.T) {
 
 	staleClaimedAt := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Microsecond)
 	_, err = db.NewUpdate().Model((*webhooks.Delivery)(nil)).Where("id = ?", delivery.ID).
-		Set("claimed_at = ?", staleClaimedAt).Exec(ctx)
+		Set("claimed_at = ?, lease_expires_at = ?", staleClaimedAt, staleClaimedAt.Add(time.Minute)).Exec(ctx)
 	require.NoError(t, err)
 	firstClaim[0].ClaimedAt = &staleClaimedAt
-	_, err = store.RecoverStaleDeliveries(ctx, 5*time.Minute)
+	_, err = store.RecoverStaleDeliveries(ctx)
 	require.NoError(t, err)
-	secondClaim, err := store.ClaimDeliveries(ctx, 1)
+	secondClaim, err := store.ClaimDeliveries(ctx, 1, testLease)
 	require.NoError(t, err)
 	require.Len(t, secondClaim, 1)
 	require.NotEqual(t, *firstClaim[0].ClaimedAt, *secondClaim[0].ClaimedAt)
@@ -489,9 +786,9 @@ func TestStaleWorkerCannotCompleteAReclaimedDelivery(t *testing.T) {
 	fresh.NextAttemptAt = nil
 	freshAttempt := staleAttempt
 	freshAttempt.ID = uuid.NewString()
-	finalStatus, err := store.CompleteDelivery(ctx, fresh, freshAttempt)
+	completion, err := store.CompleteDelivery(ctx, fresh, freshAttempt)
 	require.NoError(t, err)
-	require.Equal(t, webhooks.StatusDeliverySucceeded, finalStatus)
+	require.Equal(t, webhooks.StatusDeliverySucceeded, completion.Status)
 	attempts, _, err := store.FindDeliveryAttempts(ctx, delivery.ID, nil, 10)
 	require.NoError(t, err)
 	require.Len(t, attempts, 1, "the stale worker attempt must have rolled back")
@@ -504,7 +801,7 @@ func TestInFlightFailureBecomesCancelledWhenConfigWasDeactivated(t *testing.T) {
 	config := insertDeliveryConfig(t, store)
 	delivery := newDelivery(config.ID, "deactivated-in-flight", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second))
 	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
-	claimed, err := store.ClaimDeliveries(ctx, 1)
+	claimed, err := store.ClaimDeliveries(ctx, 1, testLease)
 	require.NoError(t, err)
 	require.Len(t, claimed, 1)
 	_, err = store.UpdateOneConfigActivation(ctx, config.ID, false)
@@ -521,15 +818,55 @@ func TestInFlightFailureBecomesCancelledWhenConfigWasDeactivated(t *testing.T) {
 		ID: uuid.NewString(), DeliveryID: failed.ID, AttemptNumber: 1,
 		Endpoint: config.Endpoint, Outcome: webhooks.OutcomeDeliveryRetryableFailure, StatusCode: 500,
 	}
-	finalStatus, err := store.CompleteDelivery(ctx, failed, attempt)
+	completion, err := store.CompleteDelivery(ctx, failed, attempt)
 	require.NoError(t, err)
-	require.Equal(t, webhooks.StatusDeliveryCancelled, finalStatus)
+	require.Equal(t, webhooks.StatusDeliveryCancelled, completion.Status)
 	stored, err := store.GetDelivery(ctx, failed.ID)
 	require.NoError(t, err)
 	require.Equal(t, webhooks.StatusDeliveryCancelled, stored.Status)
 	require.Nil(t, stored.NextAttemptAt)
 }
 
+func TestAttemptOfDeliveryCancelledInFlightIsRecorded(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	delivery := newDelivery(config.ID, "cancelled-in-flight", webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second))
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
+	claimed, err := store.ClaimDeliveries(ctx, 1, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	_, _, err = store.CancelOneDelivery(ctx, delivery.ID, webhooks.DeliveryCancellation{By: "operator"}, "cancel-in-flight")
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	statusCode := 200
+	completed := claimed[0]
+	completed.Status = webhooks.StatusDeliverySucceeded
+	completed.AttemptCount = 1
+	completed.LastAttemptAt = &now
+	completed.LastStatusCode = &statusCode
+	_, err = store.CompleteDelivery(ctx, completed, webhooks.DeliveryAttempt{
+		ID: uuid.NewString(), DeliveryID: completed.ID, AttemptNumber: 1,
+		Endpoint: config.Endpoint, Outcome: webhooks.OutcomeDeliverySucceeded, StatusCode: statusCode,
+	})
+	require.ErrorIs(t, err, storage.ErrDeliveryCancelled)
+
+	stored, err := store.GetDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryCancelled, stored.Status)
+	require.Equal(t, 1, stored.AttemptCount)
+	attempts, _, err := store.FindDeliveryAttempts(ctx, delivery.ID, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1, "the attempt went on the wire and must not be lost")
+
+	_, err = store.CompleteDelivery(ctx, completed, webhooks.DeliveryAttempt{
+		ID: uuid.NewString(), DeliveryID: completed.ID, AttemptNumber: 1,
+		Endpoint: config.Endpoint, Outcome: webhooks.OutcomeDeliverySucceeded, StatusCode: statusCode,
+	})
+	require.ErrorIs(t, err, storage.ErrDeliveryNotFound, "a completion is only recorded once")
+}
+
 func TestRecoverStaleDeliveriesRestoresActiveAndCancelsInactiveClaims(t *testing.T) {
 	store, db := newTestStoreWithDB(t)
 	ctx := context.Background()
@@ -543,18 +880,18 @@ func TestRecoverStaleDeliveriesRestoresActiveAndCancelsInactiveClaims(t *testing
 		newDelivery(recentConfig.ID, "recent-active", webhooks.StatusDeliveryPending, now),
 	}
 	require.NoError(t, store.InsertDeliveries(ctx, deliveries))
-	claimed, err := store.ClaimDeliveries(ctx, 3)
+	claimed, err := store.ClaimDeliveries(ctx, 3, testLease)
 	require.NoError(t, err)
 	require.Len(t, claimed, 3)
 	_, err = store.UpdateOneConfigActivation(ctx, inactiveConfig.ID, false)
 	require.NoError(t, err)
-	staleCutoff := time.Now().UTC().Add(-10 * time.Minute)
+	expired := time.Now().UTC().Add(-time.Second)
 	_, err = db.NewUpdate().Model((*webhooks.Delivery)(nil)).
 		Where("event_id IN (?)", bun.List([]string{"stale-active", "stale-inactive"})).
-		Set("claimed_at = ?", staleCutoff).Exec(ctx)
+		Set("lease_expires_at = ?", expired).Exec(ctx)
 	require.NoError(t, err)
 
-	recovered, err := store.RecoverStaleDeliveries(ctx, 5*time.Minute)
+	recovered, err := store.RecoverStaleDeliveries(ctx)
 	require.NoError(t, err)
 	require.EqualValues(t, 2, recovered)
 	active, err := store.GetDelivery(ctx, deliveries[0].ID)
@@ -568,6 +905,236 @@ func TestRecoverStaleDeliveriesRestoresActiveAndCancelsInactiveClaims(t *testing
 	require.Equal(t, webhooks.StatusDeliveryDelivering, recent.Status)
 }
 
+func TestHeartbeatKeepsClaimsFromRecovery(t *testing.T) {
+	store, db := newTestStoreWithDB(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Add(-time.Second)
+	deliveries := []webhooks.Delivery{
+		newDelivery(config.ID, "heartbeating", webhooks.StatusDeliveryPending, now),
+		newDelivery(config.ID, "silent", webhooks.StatusDeliveryPending, now),
+	}
+	require.NoError(t, store.InsertDeliveries(ctx, deliveries))
+	claimed, err := store.ClaimDeliveries(ctx, 2, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	for _, delivery := range claimed {
+		require.Equal(t, testLease.WorkerID, delivery.ClaimedBy)
+		require.NotNil(t, delivery.LeaseExpiresAt)
+		require.True(t, delivery.LeaseExpiresAt.After(*delivery.ClaimedAt))
+	}
+	_, err = db.NewUpdate().Model((*webhooks.Delivery)(nil)).
+		Where("id IN (?)", bun.List([]string{deliveries[0].ID, deliveries[1].ID})).
+		Set("lease_expires_at = ?", time.Now().UTC().Add(-time.Second)).Exec(ctx)
+	require.NoError(t, err)
+
+	otherWorker := webhooks.ClaimLease{WorkerID: "other-worker", Duration: time.Minute}
+	extended, err := store.ExtendDeliveryLeases(ctx, otherWorker, []string{deliveries[0].ID})
+	require.NoError(t, err)
+	require.Zero(t, extended, "only the claiming worker can extend its lease")
+	extended, err = store.ExtendDeliveryLeases(ctx, testLease, []string{deliveries[0].ID})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, extended)
+
+	recovered, err := store.RecoverStaleDeliveries(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, recovered)
+	heartbeating, err := store.GetDelivery(ctx, deliveries[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryDelivering, heartbeating.Status)
+	silent, err := store.GetDelivery(ctx, deliveries[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryPending, silent.Status)
+	require.Empty(t, silent.ClaimedBy)
+	require.Nil(t, silent.LeaseExpiresAt)
+}
+
+func TestWorkersRegistryCountsInFlightClaims(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	startedAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Microsecond)
+	require.NoError(t, store.RegisterWorker(ctx, webhooks.Worker{
+		ID: testLease.WorkerID, Hostname: "host-1", Version: "v1", StartedAt: startedAt,
+	}))
+	require.NoError(t, store.RegisterWorker(ctx, webhooks.Worker{
+		ID: "idle-worker", Hostname: "host-2", StartedAt: startedAt.Add(time.Second),
+	}))
+	now := time.Now().UTC().Add(-time.Second)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(config.ID, "registry-1", webhooks.StatusDeliveryPending, now),
+		newDelivery(config.ID, "registry-2", webhooks.StatusDeliveryPending, now),
+	}))
+	claimed, err := store.ClaimDeliveries(ctx, 2, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+
+	workers, err := store.ListWorkers(ctx)
+	require.NoError(t, err)
+	require.Len(t, workers, 2)
+	require.Equal(t, testLease.WorkerID, workers[0].ID)
+	require.Equal(t, "v1", workers[0].Version)
+	require.EqualValues(t, 2, workers[0].InFlight)
+	require.Equal(t, "idle-worker", workers[1].ID)
+	require.Zero(t, workers[1].InFlight)
+
+	// A heartbeat only refreshes the registration.
+	require.NoError(t, store.RegisterWorker(ctx, webhooks.Worker{
+		ID: testLease.WorkerID, Hostname: "host-1", Version: "v1", StartedAt: time.Now().UTC(),
+	}))
+	workers, err = store.ListWorkers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, startedAt, workers[0].StartedAt.UTC())
+	require.True(t, workers[0].LastHeartbeatAt.After(workers[1].LastHeartbeatAt))
+
+	pruned, err := store.PruneWorkers(ctx, time.Hour)
+	require.NoError(t, err)
+	require.Zero(t, pruned)
+	require.NoError(t, store.DeregisterWorker(ctx, "idle-worker"))
+	workers, err = store.ListWorkers(ctx)
+	require.NoError(t, err)
+	require.Len(t, workers, 1)
+}
+
+func TestCompleteDeliveryCountsConsecutiveFailures(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	complete := func(status, outcome string) int {
+		t.Helper()
+		delivery := newDelivery(config.ID, uuid.NewString(), webhooks.StatusDeliveryPending, time.Now().UTC().Add(-time.Second))
+		require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
+		claimed, err := store.ClaimDeliveries(ctx, 1, testLease)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		now := time.Now().UTC()
+		delivery = claimed[0]
+		delivery.Status = status
+		delivery.AttemptCount = 1
+		delivery.LastAttemptAt = &now
+		completion, err := store.CompleteDelivery(ctx, delivery, webhooks.DeliveryAttempt{
+			ID: uuid.NewString(), DeliveryID: delivery.ID, AttemptNumber: 1,
+			Endpoint: config.Endpoint, Outcome: outcome, CreatedAt: now,
+		})
+		require.NoError(t, err)
+		require.Equal(t, status, completion.Status)
+		return completion.ConsecutiveFailures
+	}
+
+	require.Equal(t, 1, complete(webhooks.StatusDeliveryFailed, webhooks.OutcomeDeliveryPermanentFailure))
+	require.Equal(t, 2, complete(webhooks.StatusDeliveryFailed, webhooks.OutcomeDeliveryPermanentFailure))
+	require.Zero(t, complete(webhooks.StatusDeliverySucceeded, webhooks.OutcomeDeliverySucceeded))
+	require.Zero(t, complete(webhooks.StatusDeliverySucceeded, webhooks.OutcomeDeliverySucceeded))
+	configs, err := store.FindManyConfigs(ctx, map[string]any{"id": config.ID})
+	require.NoError(t, err)
+	require.Zero(t, configs[0].ConsecutiveFailures)
+}
+
+func TestDisableOneConfigRecordsReasonUntilResumed(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Add(-time.Second)
+	succeeded := newDelivery(config.ID, "outcome-1", webhooks.StatusDeliverySucceeded, now)
+	failed := newDelivery(config.ID, "outcome-2", webhooks.StatusDeliveryFailed, now)
+	pending := newDelivery(config.ID, "outcome-3", webhooks.StatusDeliveryPending, now)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{succeeded, failed, pending}))
+
+	failedCount, total, err := store.CountFinishedDeliveries(ctx, config.ID, now.Add(-time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, failedCount)
+	require.EqualValues(t, 2, total)
+
+	paused, err := store.DisableOneConfig(ctx, config.ID, webhooks.DisableActionPause, "3 consecutive deliveries failed")
+	require.NoError(t, err)
+	require.NotNil(t, paused.PausedAt)
+	require.Equal(t, "3 consecutive deliveries failed", paused.DisabledReason)
+	_, err = store.DisableOneConfig(ctx, config.ID, webhooks.DisableActionPause, "again")
+	require.ErrorIs(t, err, storage.ErrConfigNotModified)
+	delivery, err := store.GetDelivery(ctx, pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryPending, delivery.Status, "pausing keeps deliveries")
+
+	resumed, err := store.ResumeOneConfig(ctx, config.ID)
+	require.NoError(t, err)
+	require.Empty(t, resumed.DisabledReason)
+	configs, err := store.FindManyConfigs(ctx, map[string]any{"id": config.ID})
+	require.NoError(t, err)
+	require.Empty(t, configs[0].DisabledReason)
+
+	deactivated, err := store.DisableOneConfig(ctx, config.ID, webhooks.DisableActionDeactivate, "failing")
+	require.NoError(t, err)
+	require.False(t, deactivated.Active)
+	delivery, err = store.GetDelivery(ctx, pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryCancelled, delivery.Status)
+	activated, err := store.UpdateOneConfigActivation(ctx, config.ID, true)
+	require.NoError(t, err)
+	require.Empty(t, activated.DisabledReason)
+}
+
+func TestOldestDueDeliveryAgeIgnoresFutureAndHeldDeliveries(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+
+	age, err := store.OldestDueDeliveryAge(ctx)
+	require.NoError(t, err)
+	require.Zero(t, age)
+
+	now := time.Now().UTC()
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(config.ID, "age-due", webhooks.StatusDeliveryPending, now.Add(-time.Minute)),
+		newDelivery(config.ID, "age-future", webhooks.StatusDeliveryPending, now.Add(time.Hour)),
+		newDelivery(config.ID, "age-done", webhooks.StatusDeliverySucceeded, now.Add(-time.Hour)),
+	}))
+	age, err = store.OldestDueDeliveryAge(ctx)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, age, time.Minute)
+	require.Less(t, age, 2*time.Minute)
+
+	// Deliveries held by a paused config are not waiting on the dispatcher.
+	paused := insertDeliveryConfig(t, store)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(paused.ID, "age-paused", webhooks.StatusDeliveryPending, now.Add(-time.Hour)),
+	}))
+	_, err = store.PauseOneConfig(ctx, paused.ID, nil)
+	require.NoError(t, err)
+	age, err = store.OldestDueDeliveryAge(ctx)
+	require.NoError(t, err)
+	require.Less(t, age, 2*time.Minute)
+}
+
+func TestConfigMetricsSelectAndCountLabelledConfigs(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	quiet := insertDeliveryConfig(t, store)
+	busy := insertDeliveryConfig(t, store)
+	monitored, err := store.InsertOneConfig(ctx, webhooks.ConfigUser{
+		Endpoint: "https://example.com/monitored", Secret: webhooks.NewSecret(), EventTypes: []string{"test.event"}, Monitor: true,
+	})
+	require.NoError(t, err)
+	now := time.Now().UTC().Add(-time.Second)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(busy.ID, "metrics-1", webhooks.StatusDeliveryPending, now),
+		newDelivery(busy.ID, "metrics-2", webhooks.StatusDeliveryPending, now),
+		newDelivery(quiet.ID, "metrics-1", webhooks.StatusDeliveryPending, now),
+		newDelivery(quiet.ID, "metrics-2", webhooks.StatusDeliverySucceeded, now),
+	}))
+
+	ids, err := store.FindMonitoredConfigIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{monitored.ID}, ids)
+
+	ids, err = store.FindBusiestConfigIDs(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{busy.ID}, ids)
+
+	counts, err := store.CountPendingDeliveriesByConfig(ctx, []string{busy.ID, quiet.ID, monitored.ID})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{busy.ID: 2, quiet.ID: 1, monitored.ID: 0}, counts)
+}
+
 func TestDeliveryRetentionCascadesAttemptsAndPurgesDeletedConfig(t *testing.T) {
 	store, db := newTestStoreWithDB(t)
 	ctx := context.Background()
@@ -583,7 +1150,7 @@ func TestDeliveryRetentionCascadesAttemptsAndPurgesDeletedConfig(t *testing.T) {
 		Outcome: webhooks.OutcomeDeliverySucceeded, StatusCode: 200, DurationMillis: &duration, CreatedAt: created,
 	}).Exec(ctx)
 	require.NoError(t, err)
-	require.NoError(t, store.DeleteOneConfig(ctx, config.ID))
+	require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModeCancel))
 
 	purged, err := store.PurgeFinishedDeliveries(ctx, 24*time.Hour, 90*24*time.Hour, 100)
 	require.NoError(t, err)
@@ -599,3 +1166,422 @@ func TestDeliveryRetentionCascadesAttemptsAndPurgesDeletedConfig(t *testing.T) {
 	require.NoError(t, err)
 	require.Zero(t, configCount)
 }
+
+// listenDeliveriesDue returns a channel receiving the due deliveries
+// notifications, once the listener is connected.
+func listenDeliveriesDue(t *testing.T, store testStore) <-chan struct{} {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	config := insertDeliveryConfig(t, store)
+	notified := make(chan struct{}, 1)
+	go func() {
+		_ = store.ListenDeliveriesDue(ctx, func() {
+			select {
+			case notified <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	// The listener connects asynchronously: enqueue until it hears one.
+	deadline := time.After(10 * time.Second)
+	for i := 0; ; i++ {
+		_, err := store.EnqueueEvent(ctx, fmt.Sprintf("event-notify-%d", i), "", "test.event", `{}`, time.Now().UTC(), time.Time{})
+		require.NoError(t, err)
+		select {
+		case <-notified:
+			require.NoError(t, store.DeleteOneConfig(ctx, config.ID, webhooks.DeleteModePurge))
+			return notified
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("enqueuing a due delivery did not notify listeners")
+		}
+	}
+}
+
+func TestEnqueueEventNotifiesDueDeliveries(t *testing.T) {
+	listenDeliveriesDue(t, newTestStore(t))
+}
+
+func TestResumingAConfigNotifiesDueDeliveries(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	_, err := store.PauseOneConfig(ctx, config.ID, nil)
+	require.NoError(t, err)
+	notified := listenDeliveriesDue(t, store)
+	select {
+	case <-notified:
+	default:
+	}
+
+	_, err = store.ResumeOneConfig(ctx, config.ID)
+	require.NoError(t, err)
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resuming a config did not notify listeners")
+	}
+}
+
+func TestDeliveryEventsFeedRecordsTransitionsAndAttempts(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	insertDeliveryConfig(t, store)
+	insertDeliveryConfig(t, store)
+	head, err := store.DeliveryEventsHead(ctx)
+	require.NoError(t, err)
+
+	enqueued, err := store.EnqueueEvent(ctx, "feed-event", "", "test.event", `{"type":"test.event"}`,
+		time.Now().UTC().Add(-time.Second), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, enqueued.Matches, 2)
+	claimed, err := store.ClaimDeliveries(ctx, 1, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	completed := claimed[0]
+	completed.Status = webhooks.StatusDeliverySucceeded
+	completed.AttemptCount = 1
+	_, err = store.CompleteDelivery(ctx, completed, webhooks.DeliveryAttempt{
+		ID: uuid.NewString(), DeliveryID: completed.ID, AttemptNumber: 1,
+		Endpoint: "https://example.com/webhooks", Outcome: webhooks.OutcomeDeliverySucceeded, StatusCode: 200,
+	})
+	require.NoError(t, err)
+
+	events, err := store.FindDeliveryEvents(ctx, webhooks.DeliveryEventFilter{After: head, ConfigID: completed.ConfigID})
+	require.NoError(t, err)
+	kinds := make([]string, 0, len(events))
+	for _, event := range events {
+		require.Equal(t, completed.ID, event.DeliveryID)
+		kinds = append(kinds, event.Type+":"+event.Status+event.Outcome)
+	}
+	require.Equal(t, []string{
+		"delivery:" + webhooks.StatusDeliveryPending,
+		"delivery:" + webhooks.StatusDeliveryDelivering,
+		"attempt:" + webhooks.OutcomeDeliverySucceeded,
+		"delivery:" + webhooks.StatusDeliverySucceeded,
+	}, kinds)
+
+	succeeded, err := store.FindDeliveryEvents(ctx, webhooks.DeliveryEventFilter{After: head, Status: webhooks.StatusDeliverySucceeded})
+	require.NoError(t, err)
+	require.Len(t, succeeded, 1)
+	require.Equal(t, completed.ID, succeeded[0].DeliveryID)
+
+	none, err := store.FindDeliveryEvents(ctx, webhooks.DeliveryEventFilter{After: events[len(events)-1].Position(), ConfigID: completed.ConfigID})
+	require.NoError(t, err)
+	require.Empty(t, none)
+}
+
+func TestDeliveryEventsFeedWaitsForRunningTransactions(t *testing.T) {
+	store, db := newTestStoreWithDB(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	head, err := store.DeliveryEventsHead(ctx)
+	require.NoError(t, err)
+
+	// The slow transaction starts first, records its event after the fast
+	// one and commits last.
+	slow, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer func() { _ = slow.Rollback() }()
+	_, err = slow.ExecContext(ctx, "SELECT pg_current_xact_id()")
+	require.NoError(t, err)
+	_, err = store.EnqueueEvent(ctx, "fast", "", "test.event", `{"type":"test.event"}`, time.Now().UTC(), time.Time{})
+	require.NoError(t, err)
+	_, err = slow.NewInsert().Model(&webhooks.DeliveryEvent{
+		Type: webhooks.DeliveryEventTypeDelivery, DeliveryID: "slow", ConfigID: config.ID, Status: webhooks.StatusDeliveryPending,
+	}).Exec(ctx)
+	require.NoError(t, err)
+
+	events, err := store.FindDeliveryEvents(ctx, webhooks.DeliveryEventFilter{After: head})
+	require.NoError(t, err)
+	require.Empty(t, events, "events committed after a running transaction must wait for it")
+
+	require.NoError(t, slow.Commit())
+	events, err = store.FindDeliveryEvents(ctx, webhooks.DeliveryEventFilter{After: head})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "slow", events[0].DeliveryID)
+	require.Greater(t, events[0].ID, events[1].ID, "the feed is ordered by transaction, not by event ID")
+}
+
+func TestFindDeliveriesSearchesEventIdentityAndPayload(t *testing.T) {
+	store, db := newTestStoreWithDB(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	target := newDelivery(config.ID, "event-1234", webhooks.StatusDeliveryFailed, now)
+	target.IdempotencyKey = "tx-1234"
+	target.EventType = "ledger.committed_transactions"
+	target.Payload = `{"type":"ledger.committed_transactions","payload":{"transactions":[{"id":1234,"reference":"abc"}]}}`
+	other := newDelivery(config.ID, "event-5678", webhooks.StatusDeliveryFailed, now)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{target, other}))
+	_, err := db.NewUpdate().Model((*webhooks.Delivery)(nil)).Where("id = ?", target.ID).
+		Set("last_status_code = 503").Exec(ctx)
+	require.NoError(t, err)
+
+	statusCode := 503
+	for name, filter := range map[string]webhooks.DeliveryFilter{
+		"event id":         {EventID: "event-1234"},
+		"idempotency key":  {IdempotencyKey: "tx-1234"},
+		"event type":       {EventType: "ledger.committed_transactions"},
+		"last status code": {LastStatusCode: &statusCode},
+		"payload":          {PayloadContains: `{"payload":{"transactions":[{"id":1234}]}}`},
+	} {
+		page, err := store.FindDeliveries(ctx, filter)
+		require.NoError(t, err, name)
+		require.Len(t, page.Data, 1, name)
+		require.Equal(t, target.ID, page.Data[0].ID, name)
+	}
+	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{PayloadContains: `{"payload":{"transactions":[{"id":4321}]}}`})
+	require.NoError(t, err)
+	require.Empty(t, page.Data)
+}
+
+func TestCancelDeliveriesRecordsActorAndIsIdempotent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	pending := newDelivery(config.ID, "cancel-one", webhooks.StatusDeliveryPending, now.Add(-time.Hour))
+	succeeded := newDelivery(config.ID, "cancel-done", webhooks.StatusDeliverySucceeded, now.Add(-time.Hour))
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{pending, succeeded}))
+
+	cancellation := webhooks.DeliveryCancellation{By: "operator", Reason: "bad event"}
+	cancelled, applied, err := store.CancelOneDelivery(ctx, pending.ID, cancellation, "cancel-key")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, webhooks.StatusDeliveryCancelled, cancelled.Status)
+	stored, err := store.GetDelivery(ctx, pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, "operator", stored.CancelledBy)
+	require.Equal(t, "bad event", stored.CancellationReason)
+	require.Nil(t, stored.NextAttemptAt)
+
+	_, applied, err = store.CancelOneDelivery(ctx, pending.ID, cancellation, "cancel-key")
+	require.NoError(t, err)
+	require.False(t, applied, "a retried request must return the recorded response")
+	_, _, err = store.CancelOneDelivery(ctx, succeeded.ID, cancellation, "cancel-key")
+	require.ErrorIs(t, err, storage.ErrIdempotencyConflict)
+	_, _, err = store.CancelOneDelivery(ctx, succeeded.ID, cancellation, "cancel-succeeded")
+	require.ErrorIs(t, err, storage.ErrDeliveryNotCancellable)
+
+	deliveries := make([]webhooks.Delivery, 0, 3)
+	for i := range 3 {
+		delivery := newDelivery(config.ID, fmt.Sprintf("bulk-cancel-%d", i), webhooks.StatusDeliveryPending, now.Add(-time.Duration(3-i)*time.Minute))
+		delivery.EventType = "ledger.committed_transactions"
+		deliveries = append(deliveries, delivery)
+	}
+	other := newDelivery(config.ID, "bulk-cancel-other", webhooks.StatusDeliveryPending, now.Add(-time.Minute))
+	require.NoError(t, store.InsertDeliveries(ctx, append(deliveries, other)))
+	request := webhooks.CancelDeliveriesRequest{
+		CreatedAtFrom: now.Add(-10 * time.Minute), CreatedAtTo: now,
+		ConfigIDs: []string{config.ID}, EventTypes: []string{"ledger.committed_transactions"},
+		Reason: "incident", PageSize: 2,
+	}
+	result, applied, err := store.CancelDeliveries(ctx, request, cancellation, "bulk-1")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, 2, result.Cancelled)
+	require.True(t, result.HasMore)
+	result, applied, err = store.CancelDeliveries(ctx, request, cancellation, "bulk-1")
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Equal(t, 2, result.Cancelled)
+	result, _, err = store.CancelDeliveries(ctx, request, cancellation, "bulk-2")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Cancelled)
+	require.False(t, result.HasMore)
+
+	stored, err = store.GetDelivery(ctx, other.ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryPending, stored.Status, "other event types must not be cancelled")
+}
+
+func TestCancellingClaimedDeliveriesDropsTheirClaims(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{
+		newDelivery(config.ID, "cancel-claimed-1", webhooks.StatusDeliveryPending, now.Add(-2*time.Minute)),
+		newDelivery(config.ID, "cancel-claimed-2", webhooks.StatusDeliveryPending, now.Add(-time.Minute)),
+	}))
+	claimed, err := store.ClaimDeliveries(ctx, 2, testLease)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+
+	cancellation := webhooks.DeliveryCancellation{By: "operator"}
+	_, _, err = store.CancelOneDelivery(ctx, claimed[0].ID, cancellation, "cancel-claimed-one")
+	require.NoError(t, err)
+	result, _, err := store.CancelDeliveries(ctx, webhooks.CancelDeliveriesRequest{
+		CreatedAtFrom: now.Add(-time.Hour), CreatedAtTo: now, ConfigIDs: []string{config.ID},
+	}, cancellation, "cancel-claimed-all")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Cancelled)
+
+	for _, delivery := range claimed {
+		stored, err := store.GetDelivery(ctx, delivery.ID)
+		require.NoError(t, err)
+		require.Equal(t, webhooks.StatusDeliveryCancelled, stored.Status)
+		require.Nil(t, stored.ClaimedAt)
+		require.Empty(t, stored.ClaimedBy)
+		require.Nil(t, stored.LeaseExpiresAt)
+	}
+}
+
+func TestRescheduleDeliveryMovesNextAttemptAndResetsRetryCycle(t *testing.T) {
+	store, db := newTestStoreWithDB(t)
+	ctx := context.Background()
+	config := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	delivery := newDelivery(config.ID, "reschedule", webhooks.StatusDeliveryPending, now.Add(-time.Hour))
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{delivery}))
+	cycleStartedAt := now.Add(-time.Hour)
+	_, err := db.NewUpdate().Model((*webhooks.Delivery)(nil)).Where("id = ?", delivery.ID).
+		Set("attempt_count = 7, cycle_started_at = ?, next_attempt_at = ?", cycleStartedAt, now.Add(time.Hour)).Exec(ctx)
+	require.NoError(t, err)
+
+	at := now.Add(2 * time.Hour)
+	rescheduled, applied, err := store.RescheduleDelivery(ctx, delivery.ID, webhooks.RescheduleDeliveryRequest{NextAttemptAt: &at}, "reschedule-1")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.True(t, at.Equal(*rescheduled.NextAttemptAt))
+	require.Equal(t, 7, rescheduled.AttemptCount)
+
+	rescheduled, applied, err = store.RescheduleDelivery(ctx, delivery.ID, webhooks.RescheduleDeliveryRequest{ResetRetryCycle: true}, "reschedule-2")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, 0, rescheduled.AttemptCount)
+	require.Nil(t, rescheduled.CycleStartedAt)
+	require.Equal(t, 1, rescheduled.ReplayGeneration)
+	require.False(t, rescheduled.NextAttemptAt.After(time.Now().UTC()), "no nextAttemptAt means retry now")
+
+	_, applied, err = store.RescheduleDelivery(ctx, delivery.ID, webhooks.RescheduleDeliveryRequest{ResetRetryCycle: true}, "reschedule-2")
+	require.NoError(t, err)
+	require.False(t, applied)
+	stored, err := store.GetDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, stored.ReplayGeneration, "a retried request must not reset the cycle twice")
+
+	done := newDelivery(config.ID, "reschedule-done", webhooks.StatusDeliverySucceeded, now)
+	require.NoError(t, store.InsertDeliveries(ctx, []webhooks.Delivery{done}))
+	_, _, err = store.RescheduleDelivery(ctx, done.ID, webhooks.RescheduleDeliveryRequest{}, "reschedule-3")
+	require.ErrorIs(t, err, storage.ErrDeliveryNotReschedulable)
+}
+
+func TestReplayToAnotherConfigCreatesLinkedDeliveries(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	source := insertDeliveryConfig(t, store)
+	target := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	failed := make([]webhooks.Delivery, 0, 3)
+	for i := range 3 {
+		failed = append(failed, newDelivery(source.ID, fmt.Sprintf("moved-%d", i), webhooks.StatusDeliveryFailed, now.Add(-time.Duration(3-i)*time.Minute)))
+	}
+	require.NoError(t, store.InsertDeliveries(ctx, failed))
+
+	copied, applied, err := store.ReplayDeliveryToConfig(ctx, failed[0].ID, target.ID, "replay-to-target")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, target.ID, copied.ConfigID)
+	require.Equal(t, failed[0].ID, copied.ReplayedFrom)
+	require.Equal(t, failed[0].EventID, copied.EventID)
+	require.Equal(t, webhooks.StatusDeliveryPending, copied.Status)
+	original, err := store.GetDelivery(ctx, failed[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.StatusDeliveryFailed, original.Status, "the original delivery must not be mutated")
+	_, _, err = store.ReplayDeliveryToConfig(ctx, failed[0].ID, target.ID, "replay-to-target-again")
+	require.ErrorIs(t, err, storage.ErrDeliveryNotReplayable, "the target already has a delivery for this event")
+	_, _, err = store.ReplayDeliveryToConfig(ctx, failed[0].ID, uuid.NewString(), "replay-to-missing")
+	require.ErrorIs(t, err, storage.ErrConfigNotFound)
+
+	request := webhooks.ReplayDeliveriesRequest{
+		CreatedAtFrom: now.Add(-time.Hour), CreatedAtTo: now,
+		Statuses:  []string{webhooks.StatusDeliveryFailed},
+		ConfigIDs: []string{source.ID}, TargetConfigID: target.ID, PageSize: 2,
+	}
+	result, applied, err := store.ReplayDeliveries(ctx, request, "bulk-to-target-1")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, 1, result.Replayed)
+	require.Equal(t, 1, result.Skipped)
+	require.True(t, result.HasMore)
+	// The next page is requested with the token clients get, which must keep
+	// the target.
+	cursor, err := webhooks.DecodeReplayDeliveryCursor(result.NextCursorToken)
+	require.NoError(t, err)
+	require.Equal(t, target.ID, cursor.TargetConfigID)
+	request.Cursor = &cursor.Position
+	result, _, err = store.ReplayDeliveries(ctx, request, "bulk-to-target-2")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Replayed)
+	require.False(t, result.HasMore)
+
+	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{ConfigID: target.ID})
+	require.NoError(t, err)
+	require.Len(t, page.Data, 3)
+	for _, delivery := range page.Data {
+		require.NotEmpty(t, delivery.ReplayedFrom)
+	}
+}
+
+func TestBackfillConfigEnqueuesDistinctMatchingEvents(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	first := insertDeliveryConfig(t, store)
+	second := insertDeliveryConfig(t, store)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	history := []webhooks.Delivery{
+		newDelivery(first.ID, "history-1", webhooks.StatusDeliverySucceeded, now.Add(-3*time.Hour)),
+		newDelivery(second.ID, "history-1", webhooks.StatusDeliveryFailed, now.Add(-3*time.Hour)),
+		newDelivery(first.ID, "history-2", webhooks.StatusDeliverySucceeded, now.Add(-2*time.Hour)),
+		newDelivery(first.ID, "history-3", webhooks.StatusDeliverySucceeded, now.Add(-time.Hour)),
+	}
+	unrelated := newDelivery(first.ID, "history-other-type", webhooks.StatusDeliverySucceeded, now.Add(-time.Hour))
+	unrelated.EventType = "other.event"
+	tooOld := newDelivery(first.ID, "history-too-old", webhooks.StatusDeliverySucceeded, now.Add(-48*time.Hour))
+	// A later delivery of an event of the first page, replayed to a third
+	// config past the cursor, must not bring the event back.
+	third := insertDeliveryConfig(t, store)
+	replayed := newDelivery(third.ID, "history-1", webhooks.StatusDeliverySucceeded, now.Add(-30*time.Minute))
+	require.NoError(t, store.InsertDeliveries(ctx, append(history, unrelated, tooOld, replayed)))
+	target := insertDeliveryConfig(t, store)
+
+	request := webhooks.BackfillConfigRequest{CreatedAtFrom: now.Add(-24 * time.Hour), CreatedAtTo: now, PageSize: 2}
+	result, applied, err := store.BackfillConfig(ctx, target.ID, request, "backfill-1")
+	require.NoError(t, err)
+	require.True(t, applied)
+	require.Equal(t, 2, result.Enqueued)
+	require.True(t, result.HasMore)
+	cached, applied, err := store.BackfillConfig(ctx, target.ID, request, "backfill-1")
+	require.NoError(t, err)
+	require.False(t, applied)
+	require.Equal(t, result, cached)
+
+	request.Cursor = result.NextCursor
+	request.CursorToken = result.NextCursorToken
+	result, _, err = store.BackfillConfig(ctx, target.ID, request, "backfill-2")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Enqueued)
+	require.Zero(t, result.Skipped)
+	require.False(t, result.HasMore)
+
+	page, err := store.FindDeliveries(ctx, webhooks.DeliveryFilter{ConfigID: target.ID})
+	require.NoError(t, err)
+	eventIDs := make([]string, 0, len(page.Data))
+	for _, delivery := range page.Data {
+		require.Equal(t, webhooks.StatusDeliveryPending, delivery.Status)
+		require.Empty(t, delivery.ReplayedFrom, "a backfill is not a replay")
+		eventIDs = append(eventIDs, delivery.EventID)
+	}
+	require.ElementsMatch(t, []string{"history-1", "history-2", "history-3"}, eventIDs)
+
+	request = webhooks.BackfillConfigRequest{CreatedAtFrom: now.Add(-24 * time.Hour), CreatedAtTo: now}
+	result, _, err = store.BackfillConfig(ctx, target.ID, request, "backfill-3")
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Enqueued)
+	require.Equal(t, 3, result.Skipped, "events already enqueued for the config are skipped")
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/logging"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgxlisten"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	maxDeliveryEventsPageSize = 1000
+	listenReconnectDelay      = 5 * time.Second
+)
+
+// withDeliveryEvents wraps q, a statement inserting deliveries or changing
+// their status and returning at least their id, config_id and status, so that
+// it also records one delivery event per returned row. The wrapping query
+// returns the rows of q.
+func withDeliveryEvents(db bun.IDB, q schema.QueryAppender) *bun.RawQuery {
+	return db.NewRaw(`
+		WITH changed AS (?), recorded AS (
+			INSERT INTO delivery_events (type, delivery_id, config_id, status)
+			SELECT ?, changed.id, changed.config_id, changed.status FROM changed
+		)
+		SELECT * FROM changed
+	`, q, webhooks.DeliveryEventTypeDelivery)
+}
+
+// recordAttemptEvent records the delivery event of a new attempt of a
+// delivery of configID.
+func recordAttemptEvent(ctx context.Context, db bun.IDB, configID string, attempt webhooks.DeliveryAttempt) error {
+	statusCode := attempt.StatusCode
+	_, err := db.NewInsert().Model(&webhooks.DeliveryEvent{
+		Type: webhooks.DeliveryEventTypeAttempt, DeliveryID: attempt.DeliveryID, ConfigID: configID,
+		AttemptID: attempt.ID, Outcome: attempt.Outcome, StatusCode: &statusCode,
+	}).Exec(ctx)
+	return errors.Wrap(err, "recording delivery attempt event")
+}
+
+// FindDeliveryEvents only returns events of the transactions older than the
+// oldest one still running: an event of a running transaction may commit
+// after later events were read, and would be skipped by readers resuming from
+// their position. A long running transaction holds the feed back until it
+// ends.
+func (s Store) FindDeliveryEvents(ctx context.Context, filter webhooks.DeliveryEventFilter) ([]webhooks.DeliveryEvent, error) {
+	if filter.Limit <= 0 || filter.Limit > maxDeliveryEventsPageSize {
+		filter.Limit = maxDeliveryEventsPageSize
+	}
+	res := []webhooks.DeliveryEvent{}
+	q := s.db.NewSelect().Model(&res).
+		ColumnExpr("delivery_event.*").
+		Where("(xid, id) > (?::xid8, ?)", strconv.FormatUint(filter.After.TxID, 10), filter.After.ID).
+		Where("xid < pg_snapshot_xmin(pg_current_snapshot())").
+		OrderExpr("xid ASC, id ASC").
+		Limit(filter.Limit)
+	if filter.ConfigID != "" {
+		q = q.Where("config_id = ?", filter.ConfigID)
+	}
+	if filter.Status != "" {
+		q = q.Where("type = ? AND status = ?", webhooks.DeliveryEventTypeDelivery, filter.Status)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, errors.Wrap(err, "finding delivery events")
+	}
+	return res, nil
+}
+
+// DeliveryEventsHead returns the position from which only the events of
+// transactions still running or yet to start are read.
+func (s Store) DeliveryEventsHead(ctx context.Context) (webhooks.DeliveryEventPosition, error) {
+	position := webhooks.DeliveryEventPosition{}
+	err := s.db.NewRaw("SELECT pg_snapshot_xmin(pg_current_snapshot())").Scan(ctx, &position.TxID)
+	return position, errors.Wrap(err, "reading delivery events head")
+}
+
+// listen calls handle with the payload of every notification on channel until
+// ctx is done. It uses a dedicated connection, outside of the pool, which is
+// re-established when lost.
+func (s Store) listen(ctx context.Context, channel string, handle func(payload string)) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "acquiring connection for %s listener", channel)
+	}
+	var connString string
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unsupported driver connection %T", driverConn)
+		}
+		connString = pgxConn.Conn().Config().ConnString()
+		return nil
+	})
+	_ = conn.Close()
+	if err != nil {
+		return errors.Wrapf(err, "reading connection string for %s listener", channel)
+	}
+
+	listener := pgxlisten.Listener{
+		Connect: func(ctx context.Context) (*pgx.Conn, error) {
+			return pgx.Connect(ctx, connString)
+		},
+		LogError: func(ctx context.Context, err error) {
+			if !errors.Is(err, context.Canceled) {
+				logging.FromContext(ctx).Errorf("listening on %s: %s", channel, err)
+			}
+		},
+		ReconnectDelay: listenReconnectDelay,
+	}
+	listener.Handle(channel, pgxlisten.HandlerFunc(
+		func(_ context.Context, notification *pgconn.Notification, _ *pgx.Conn) error {
+			handle(notification.Payload)
+			return nil
+		}))
+	err = listener.Listen(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
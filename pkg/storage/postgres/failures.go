@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// recordConfigOutcome resets the consecutive failures of a config after a
+// successful delivery, or increments them after a failed one. cfg must be
+// locked by tx; nothing is written when the count does not change.
+func recordConfigOutcome(ctx context.Context, tx bun.Tx, cfg webhooks.Config, status string) (int, error) {
+	failures := cfg.ConsecutiveFailures
+	switch status {
+	case webhooks.StatusDeliverySucceeded:
+		failures = 0
+	case webhooks.StatusDeliveryFailed:
+		failures++
+	}
+	if failures == cfg.ConsecutiveFailures {
+		return failures, nil
+	}
+	_, err := tx.NewUpdate().Model((*webhooks.Config)(nil)).
+		Where("id = ?", cfg.ID).
+		Set("consecutive_failures = ?", failures).
+		Exec(ctx)
+	return failures, errors.Wrap(err, "recording config outcome")
+}
+
+// CountFinishedDeliveries counts the deliveries of a config that succeeded or
+// failed since the given time, and how many of them failed.
+func (s Store) CountFinishedDeliveries(ctx context.Context, configID string, since time.Time) (failed, total int64, err error) {
+	row := struct {
+		Failed int64 `bun:"failed"`
+		Total  int64 `bun:"total"`
+	}{}
+	err = s.db.NewSelect().Model((*webhooks.Delivery)(nil)).
+		ColumnExpr("COUNT(*) FILTER (WHERE status = ?) AS failed", webhooks.StatusDeliveryFailed).
+		ColumnExpr("COUNT(*) AS total").
+		Where("config_id = ?", configID).
+		Where("status IN (?, ?)", webhooks.StatusDeliverySucceeded, webhooks.StatusDeliveryFailed).
+		Where("updated_at >= ?", since).
+		Scan(ctx, &row)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "counting finished deliveries")
+	}
+	return row.Failed, row.Total, nil
+}
+
+// DisableOneConfig deactivates or pauses an active, unpaused config on behalf
+// of the failure policy, recording why. It returns ErrConfigNotModified when
+// the config is already disabled, so that only one worker reports it.
+func (s Store) DisableOneConfig(ctx context.Context, id, action, reason string) (webhooks.Config, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.Config{}, errors.Wrap(err, "beginning config disable transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	cfg := webhooks.Config{}
+	if err := tx.NewSelect().Model(&cfg).
+		Where("id = ?", id).Where("deleted_at IS NULL").For("UPDATE").Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webhooks.Config{}, storage.ErrConfigNotFound
+		}
+		return webhooks.Config{}, errors.Wrap(err, "selecting one config before disabling")
+	}
+	if !cfg.Active || cfg.PausedAt != nil || cfg.DrainingSince != nil {
+		return cfg, storage.ErrConfigNotModified
+	}
+
+	now := time.Now().UTC()
+	q := tx.NewUpdate().Model((*webhooks.Config)(nil)).
+		Where("id = ?", id).
+		Set("disabled_reason = ?", reason).
+		Set("consecutive_failures = 0").
+		Set("updated_at = ?", now)
+	switch action {
+	case webhooks.DisableActionPause:
+		q = q.Set("paused_at = ?", now)
+		cfg.PausedAt = &now
+	default:
+		q = q.Set("active = false")
+		cfg.Active = false
+	}
+	if _, err := q.Exec(ctx); err != nil {
+		return webhooks.Config{}, errors.Wrap(err, "disabling one config")
+	}
+	if !cfg.Active {
+		if err := cancelPendingDeliveries(ctx, tx, id, now); err != nil {
+			return webhooks.Config{}, errors.Wrap(err, "cancelling disabled config deliveries")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return webhooks.Config{}, errors.Wrap(err, "committing config disable")
+	}
+
+	cfg.DisabledReason = reason
+	cfg.ConsecutiveFailures = 0
+	cfg.UpdatedAt = now
+	return cfg, nil
+}
+
+func clearDisabledReason(ctx context.Context, db bun.IDB, id string) error {
+	_, err := db.NewUpdate().Model((*webhooks.Config)(nil)).
+		Where("id = ?", id).
+		Set("disabled_reason = NULL, consecutive_failures = 0").
+		Exec(ctx)
+	return errors.Wrap(err, "clearing config disabled reason")
+}
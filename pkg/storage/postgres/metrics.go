@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// FindMonitoredConfigIDs returns up to limit live configs carrying the monitor
+// flag.
+func (s Store) FindMonitoredConfigIDs(ctx context.Context, limit int) ([]string, error) {
+	ids := []string{}
+	err := s.db.NewSelect().Model((*webhooks.Config)(nil)).
+		Column("id").
+		Where("monitor").
+		Where("deleted_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Scan(ctx, &ids)
+	return ids, errors.Wrap(err, "finding monitored configs")
+}
+
+// FindBusiestConfigIDs returns up to limit configs with the most pending
+// deliveries, the most backlogged first.
+func (s Store) FindBusiestConfigIDs(ctx context.Context, limit int) ([]string, error) {
+	ids := []string{}
+	err := s.db.NewRaw(`
+		SELECT config_id
+		FROM deliveries
+		WHERE status = ?
+		GROUP BY config_id
+		ORDER BY COUNT(*) DESC, config_id
+		LIMIT ?
+	`, webhooks.StatusDeliveryPending, limit).Scan(ctx, &ids)
+	return ids, errors.Wrap(err, "finding busiest configs")
+}
+
+// CountPendingDeliveriesByConfig counts the pending deliveries of the given
+// configs, each count capped at 1000000.
+func (s Store) CountPendingDeliveriesByConfig(ctx context.Context, ids []string) (map[string]int64, error) {
+	if len(ids) == 0 {
+		return map[string]int64{}, nil
+	}
+	rows := []struct {
+		ConfigID string `bun:"config_id"`
+		Count    int64  `bun:"count"`
+	}{}
+	err := s.db.NewRaw(`
+		SELECT configs.id AS config_id, (
+			SELECT COUNT(*) FROM (
+				SELECT 1 FROM deliveries WHERE status = ? AND config_id = configs.id LIMIT 1000000
+			) pending
+		) AS count
+		FROM unnest(?::varchar[]) AS configs(id)
+	`, webhooks.StatusDeliveryPending, pgdialect.Array(ids)).Scan(ctx, &rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting pending deliveries by config")
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ConfigID] = row.Count
+	}
+	return counts, nil
+}
@@ -1,19 +1,40 @@
 package postgres
 
 import (
+	"context"
+
+	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
 
 	"github.com/formancehq/go-libs/v2/bun/bunconnect"
 
+	"github.com/formancehq/webhooks/pkg/health"
 	"github.com/formancehq/webhooks/pkg/storage"
 	"go.uber.org/fx"
 )
 
+var errMigrationsPending = errors.New("migrations are pending")
+
 func NewModule(connectionOptions bunconnect.ConnectionOptions, debug bool) fx.Option {
 	return fx.Options(
 		bunconnect.Module(connectionOptions, debug),
 		fx.Provide(func(db *bun.DB) (storage.Store, error) {
 			return NewStore(db)
 		}),
+		health.ProvideCheck(func(db *bun.DB) health.Check {
+			return health.Check{Name: "postgres", Check: db.PingContext}
+		}),
+		health.ProvideCheck(func(db *bun.DB) health.Check {
+			return health.Check{Name: "migrations", Check: func(ctx context.Context) error {
+				upToDate, err := storage.IsUpToDate(ctx, db)
+				if err != nil {
+					return err
+				}
+				if !upToDate {
+					return errMigrationsPending
+				}
+				return nil
+			}}
+		}),
 	)
 }
@@ -31,6 +31,8 @@ func (s Store) FindManyConfigs(ctx context.Context, filters map[string]any) ([]w
 		switch key {
 		case "id":
 			sq = sq.Where("id = ?", val)
+		case "name":
+			sq = sq.Where("name = ?", val)
 		case "endpoint":
 			sq = sq.Where("endpoint = ?", val)
 		case "active":
@@ -59,13 +61,33 @@ func (s Store) InsertOneConfig(ctx context.Context, cfgUser webhooks.ConfigUser)
 }
 
 func (s Store) UpdateOneConfig(ctx context.Context, id string, cfgUser webhooks.ConfigUser) error {
+	maintenance := webhooks.Config{ConfigUser: cfgUser}
+	maintenance.ScheduleMaintenance(time.Now().UTC())
+	var maintenanceWindows any
+	if len(cfgUser.MaintenanceWindows) > 0 {
+		maintenanceWindows = cfgUser.MaintenanceWindows
+	}
+	var eventTypePriorities any
+	if len(cfgUser.EventTypePriorities) > 0 {
+		eventTypePriorities = cfgUser.EventTypePriorities
+	}
 	if _, err := s.db.NewUpdate().
 		Model(&webhooks.Config{}).
 		Where("id = ?", id).
 		Where("deleted_at IS NULL").
+		// A request without a name keeps the stored one.
+		Set("name = COALESCE(NULLIF(?, ''), name)", cfgUser.Name).
 		Set("endpoint = ?", cfgUser.Endpoint).
 		Set("secret = ?", cfgUser.Secret).
 		Set("event_types = ?", pgdialect.Array(cfgUser.EventTypes)).
+		Set("maintenance_windows = ?", maintenanceWindows).
+		Set("capture_attempts = ?", cfgUser.CaptureAttempts).
+		Set("monitor = ?", cfgUser.Monitor).
+		Set("owner_contact_url = NULLIF(?, '')", cfgUser.OwnerContactURL).
+		Set("priority = NULLIF(?, '')", cfgUser.Priority).
+		Set("event_type_priorities = ?", eventTypePriorities).
+		Set("maintenance_starts_at = ?", maintenance.MaintenanceStartsAt).
+		Set("maintenance_ends_at = ?", maintenance.MaintenanceEndsAt).
 		Exec(ctx); err != nil {
 		return errors.Wrap(err, "updating config")
 	}
@@ -73,32 +95,94 @@ func (s Store) UpdateOneConfig(ctx context.Context, id string, cfgUser webhooks.
 	return nil
 }
 
-func (s Store) DeleteOneConfig(ctx context.Context, id string) error {
+func (s Store) DeleteOneConfig(ctx context.Context, id, mode string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "beginning config deletion")
 	}
 	defer func() { _ = tx.Rollback() }()
 	cfg := webhooks.Config{}
-	if err := tx.NewSelect().Model(&cfg).
-		Where("id = ?", id).Where("deleted_at IS NULL").For("UPDATE").Scan(ctx); err != nil {
+	q := tx.NewSelect().Model(&cfg).Where("id = ?", id).For("UPDATE")
+	// A purge also erases configs already deleted by the other modes, whose
+	// deliveries and payloads outlive them.
+	if mode != webhooks.DeleteModePurge {
+		q = q.Where("deleted_at IS NULL")
+	}
+	if err := q.Scan(ctx); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return storage.ErrConfigNotFound
 		}
 		return errors.Wrap(err, "selecting one config before deleting")
 	}
 	now := time.Now().UTC()
-	if _, err := tx.NewUpdate().Model((*webhooks.Config)(nil)).
-		Where("id = ?", id).
-		Set("active = false, deleted_at = ?, updated_at = ?", now, now).Exec(ctx); err != nil {
-		return errors.Wrap(err, "soft deleting config")
-	}
-	if err := cancelPendingDeliveries(ctx, tx, id, now); err != nil {
-		return errors.Wrap(err, "cancelling deleted config deliveries")
+	switch mode {
+	case webhooks.DeleteModeDrain:
+		if cfg.DrainingSince == nil {
+			if _, err := tx.NewUpdate().Model((*webhooks.Config)(nil)).
+				Where("id = ?", id).
+				Set("draining_since = ?, updated_at = ?", now, now).Exec(ctx); err != nil {
+				return errors.Wrap(err, "marking config as draining")
+			}
+		}
+		if _, err := finalizeDrainedConfigs(ctx, tx, id, now); err != nil {
+			return err
+		}
+	case webhooks.DeleteModePurge:
+		if _, err := tx.NewDelete().Model((*webhooks.Delivery)(nil)).
+			Where("config_id = ?", id).Exec(ctx); err != nil {
+			return errors.Wrap(err, "purging config deliveries")
+		}
+		if _, err := tx.NewDelete().Model((*webhooks.DeliveryEvent)(nil)).
+			Where("config_id = ?", id).Exec(ctx); err != nil {
+			return errors.Wrap(err, "purging config delivery events")
+		}
+		if _, err := tx.NewDelete().Model((*webhooks.Attempt)(nil)).
+			Where("config->>'id' = ?", id).Exec(ctx); err != nil {
+			return errors.Wrap(err, "purging config attempts")
+		}
+		if _, err := tx.NewDelete().Model((*webhooks.Config)(nil)).
+			Where("id = ?", id).Exec(ctx); err != nil {
+			return errors.Wrap(err, "purging config")
+		}
+	default:
+		if _, err := tx.NewUpdate().Model((*webhooks.Config)(nil)).
+			Where("id = ?", id).
+			Set("active = false, deleted_at = ?, updated_at = ?", now, now).Exec(ctx); err != nil {
+			return errors.Wrap(err, "soft deleting config")
+		}
+		if err := cancelPendingDeliveries(ctx, tx, id, now); err != nil {
+			return errors.Wrap(err, "cancelling deleted config deliveries")
+		}
 	}
 	return errors.Wrap(tx.Commit(), "committing config deletion")
 }
 
+// FinalizeDrainedConfigs soft deletes the draining configs which no longer
+// have pending or in-flight deliveries.
+func (s Store) FinalizeDrainedConfigs(ctx context.Context) (int64, error) {
+	return finalizeDrainedConfigs(ctx, s.db, "", time.Now().UTC())
+}
+
+func finalizeDrainedConfigs(ctx context.Context, db bun.IDB, configID string, now time.Time) (int64, error) {
+	q := db.NewUpdate().Model((*webhooks.Config)(nil)).
+		Where("draining_since IS NOT NULL").
+		Where("deleted_at IS NULL").
+		Where(`NOT EXISTS (
+			SELECT 1 FROM deliveries d
+			WHERE d.config_id = config.id AND d.status IN (?)
+		)`, bun.List([]string{webhooks.StatusDeliveryPending, webhooks.StatusDeliveryDelivering})).
+		Set("active = false, deleted_at = ?, updated_at = ?", now, now)
+	if configID != "" {
+		q = q.Where("id = ?", configID)
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "finalizing drained configs")
+	}
+	count, err := res.RowsAffected()
+	return count, errors.Wrap(err, "reading finalized config count")
+}
+
 func (s Store) UpdateOneConfigActivation(ctx context.Context, id string, active bool) (webhooks.Config, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -126,6 +210,15 @@ func (s Store) UpdateOneConfigActivation(ctx context.Context, id string, active
 		Exec(ctx); err != nil {
 		return webhooks.Config{}, errors.Wrap(err, "updating one config activation")
 	}
+	if active {
+		if err := clearDisabledReason(ctx, tx, id); err != nil {
+			return webhooks.Config{}, err
+		}
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return webhooks.Config{}, err
+		}
+		cfg.DisabledReason = ""
+	}
 	if !active {
 		if err := cancelPendingDeliveries(ctx, tx, id, now); err != nil {
 			return webhooks.Config{}, errors.Wrap(err, "cancelling deactivated config deliveries")
@@ -141,14 +234,157 @@ func (s Store) UpdateOneConfigActivation(ctx context.Context, id string, active
 }
 
 func cancelPendingDeliveries(ctx context.Context, db bun.IDB, configID string, now time.Time) error {
-	_, err := db.NewUpdate().Model((*webhooks.Delivery)(nil)).
+	_, err := withDeliveryEvents(db, db.NewUpdate().Model((*webhooks.Delivery)(nil)).
 		Where("config_id = ?", configID).
 		Where("status = ?", webhooks.StatusDeliveryPending).
 		Set("status = ?, next_attempt_at = NULL, updated_at = ?", webhooks.StatusDeliveryCancelled, now).
+		Returning("id, config_id, status")).
 		Exec(ctx)
 	return err
 }
 
+func (s Store) PauseOneConfig(ctx context.Context, id string, resumeAt *time.Time) (webhooks.Config, error) {
+	now := time.Now().UTC()
+	cfg := webhooks.Config{}
+	if err := s.db.NewUpdate().Model(&cfg).
+		Where("id = ?", id).
+		Where("deleted_at IS NULL").
+		Set("paused_at = COALESCE(paused_at, ?)", now).
+		Set("resume_at = ?", resumeAt).
+		Set("updated_at = ?", now).
+		Returning("*").
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webhooks.Config{}, storage.ErrConfigNotFound
+		}
+		return webhooks.Config{}, errors.Wrap(err, "pausing one config")
+	}
+	return cfg, nil
+}
+
+func (s Store) ResumeOneConfig(ctx context.Context, id string) (webhooks.Config, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhooks.Config{}, errors.Wrap(err, "beginning config resume transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	cfg := webhooks.Config{}
+	if err := tx.NewSelect().Model(&cfg).
+		Where("id = ?", id).Where("deleted_at IS NULL").For("UPDATE").Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webhooks.Config{}, storage.ErrConfigNotFound
+		}
+		return webhooks.Config{}, errors.Wrap(err, "selecting one config before resuming")
+	}
+	if cfg.PausedAt == nil {
+		return cfg, storage.ErrConfigNotModified
+	}
+	now := time.Now().UTC()
+	if _, err := resumeConfigs(ctx, tx, id, now); err != nil {
+		return webhooks.Config{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return webhooks.Config{}, errors.Wrap(err, "committing config resume")
+	}
+	cfg.PausedAt = nil
+	cfg.ResumeAt = nil
+	cfg.DisabledReason = ""
+	cfg.UpdatedAt = now
+	return cfg, nil
+}
+
+// ResumeDueConfigs clears the pause of the configs whose scheduled resume
+// time has passed. Their deliveries are already claimable at that point.
+func (s Store) ResumeDueConfigs(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "beginning scheduled resume transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	count, err := resumeConfigs(ctx, tx, "", time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return count, errors.Wrap(tx.Commit(), "committing scheduled resume")
+}
+
+// resumeConfigs clears the pause of one config, or of every config due for
+// resume when configID is empty. The retry cycle of paused deliveries is
+// shifted by the pause duration so that the pause does not eat the retry window.
+func resumeConfigs(ctx context.Context, tx bun.Tx, configID string, now time.Time) (int64, error) {
+	paused := []webhooks.Config{}
+	q := tx.NewSelect().Model(&paused).Where("paused_at IS NOT NULL").For("UPDATE")
+	if configID != "" {
+		q = q.Where("id = ?", configID)
+	} else {
+		q = q.Where("resume_at <= ?", now)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return 0, errors.Wrap(err, "selecting configs to resume")
+	}
+	for _, cfg := range paused {
+		resumedAt := now
+		if cfg.ResumeAt != nil && cfg.ResumeAt.Before(now) {
+			resumedAt = *cfg.ResumeAt
+		}
+		if _, err := tx.NewUpdate().Model((*webhooks.Delivery)(nil)).
+			Where("config_id = ?", cfg.ID).
+			Where("status = ?", webhooks.StatusDeliveryPending).
+			Where("cycle_started_at IS NOT NULL").
+			Set("cycle_started_at = cycle_started_at + ?::interval", fmt.Sprintf("%d microseconds", resumedAt.Sub(*cfg.PausedAt).Microseconds())).
+			Exec(ctx); err != nil {
+			return 0, errors.Wrap(err, "shifting retry cycle of resumed deliveries")
+		}
+		if _, err := tx.NewUpdate().Model((*webhooks.Config)(nil)).
+			Where("id = ?", cfg.ID).
+			Set("paused_at = NULL, resume_at = NULL, updated_at = ?", now).
+			Exec(ctx); err != nil {
+			return 0, errors.Wrap(err, "resuming config")
+		}
+		if err := clearDisabledReason(ctx, tx, cfg.ID); err != nil {
+			return 0, err
+		}
+	}
+	if len(paused) > 0 {
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(paused)), nil
+}
+
+// RefreshMaintenanceWindows moves the maintenance bounds of the configs whose
+// window has ended to their next window.
+func (s Store) RefreshMaintenanceWindows(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "beginning maintenance refresh transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	now := time.Now().UTC()
+	configs := []webhooks.Config{}
+	if err := tx.NewSelect().Model(&configs).
+		Where("deleted_at IS NULL").
+		Where("maintenance_ends_at <= ?", now).
+		For("UPDATE SKIP LOCKED").Scan(ctx); err != nil {
+		return 0, errors.Wrap(err, "selecting configs with ended maintenance windows")
+	}
+	for _, cfg := range configs {
+		cfg.ScheduleMaintenance(now)
+		if _, err := tx.NewUpdate().Model(&cfg).
+			Column("maintenance_starts_at", "maintenance_ends_at").
+			WherePK().Exec(ctx); err != nil {
+			return 0, errors.Wrap(err, "refreshing config maintenance window")
+		}
+	}
+	if len(configs) > 0 {
+		if err := notifyDeliveriesDue(ctx, tx); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(configs)), errors.Wrap(tx.Commit(), "committing maintenance refresh")
+}
+
 func (s Store) UpdateOneConfigSecret(ctx context.Context, id, secret string) (webhooks.Config, error) {
 	cfg := webhooks.Config{}
 	if err := s.db.NewSelect().Model(&cfg).
@@ -175,6 +411,36 @@ func (s Store) UpdateOneConfigSecret(ctx context.Context, id, secret string) (we
 	return cfg, nil
 }
 
+// ImportConfigs inserts configs and deliveries with their IDs unchanged. Rows
+// that already exist are skipped, so that importing twice is a no-op.
+func (s Store) ImportConfigs(ctx context.Context, configs []webhooks.Config, deliveries []webhooks.Delivery) (webhooks.ConfigImportResult, error) {
+	result := webhooks.ConfigImportResult{}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, errors.Wrap(err, "beginning config import transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if len(configs) > 0 {
+		res, err := tx.NewInsert().Model(&configs).On("CONFLICT (id) DO NOTHING").Exec(ctx)
+		if err != nil {
+			return result, errors.Wrap(err, "importing configs")
+		}
+		affected, _ := res.RowsAffected()
+		result.ConfigsImported = int(affected)
+		result.ConfigsSkipped = len(configs) - result.ConfigsImported
+	}
+	if len(deliveries) > 0 {
+		res, err := tx.NewInsert().Model(&deliveries).On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return result, errors.Wrap(err, "importing deliveries")
+		}
+		affected, _ := res.RowsAffected()
+		result.DeliveriesImported = int(affected)
+		result.DeliveriesSkipped = len(deliveries) - result.DeliveriesImported
+	}
+	return result, errors.Wrap(tx.Commit(), "committing config import")
+}
+
 func (s Store) Close(ctx context.Context) error {
 	return s.db.Close()
 }
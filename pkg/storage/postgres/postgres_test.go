@@ -3,6 +3,7 @@ package postgres_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/formancehq/go-libs/v2/bun/bundebug"
 	"github.com/uptrace/bun"
@@ -76,6 +77,7 @@ func TestConfigsInsert(t *testing.T) {
 	})
 
 	cfgUser := webhooks.ConfigUser{
+		Name:       "orders",
 		Endpoint:   "http://localhost:8080",
 		Secret:     "foo",
 		EventTypes: []string{"A", "B"},
@@ -101,4 +103,47 @@ func TestConfigsInsert(t *testing.T) {
 	})
 	require.NoError(t, err)
 	require.Equal(t, 0, len(cfgs))
+
+	cfgs, err = store.FindManyConfigs(context.Background(), map[string]any{
+		"id": cfg.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "orders", cfgs[0].Name, "an update without a name keeps it")
+
+	err = store.UpdateOneConfig(context.Background(), cfg.ID, webhooks.ConfigUser{
+		Name:       "payments",
+		Endpoint:   "http://localhost:8080",
+		Secret:     "foo",
+		EventTypes: []string{"B"},
+	})
+	require.NoError(t, err)
+	cfgs, err = store.FindManyConfigs(context.Background(), map[string]any{
+		"id": cfg.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "payments", cfgs[0].Name)
+}
+
+func TestImportConfigsIsIdempotent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	config := webhooks.NewConfig(webhooks.ConfigUser{
+		Name: "orders", Endpoint: "https://example.com/orders", Secret: webhooks.NewSecret(),
+		EventTypes: []string{"test.event"},
+	})
+	delivery := newDelivery(config.ID, "event-1", webhooks.StatusDeliveryPending, now)
+
+	result, err := store.ImportConfigs(ctx, []webhooks.Config{config}, []webhooks.Delivery{delivery})
+	require.NoError(t, err)
+	require.Equal(t, webhooks.ConfigImportResult{ConfigsImported: 1, DeliveriesImported: 1}, result)
+
+	result, err = store.ImportConfigs(ctx, []webhooks.Config{config}, []webhooks.Delivery{delivery})
+	require.NoError(t, err)
+	require.Equal(t, webhooks.ConfigImportResult{ConfigsSkipped: 1, DeliveriesSkipped: 1}, result)
+
+	configs, err := store.FindManyConfigs(ctx, map[string]any{"name": "orders"})
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	require.Equal(t, config.ID, configs[0].ID)
 }
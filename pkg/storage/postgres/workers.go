@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/pkg/errors"
+)
+
+func (s Store) RegisterWorker(ctx context.Context, worker webhooks.Worker) error {
+	worker.LastHeartbeatAt = time.Now().UTC()
+	_, err := s.db.NewInsert().Model(&worker).
+		On("CONFLICT (id) DO UPDATE").
+		Set("last_heartbeat_at = EXCLUDED.last_heartbeat_at").
+		Exec(ctx)
+	return errors.Wrap(err, "registering worker")
+}
+
+func (s Store) DeregisterWorker(ctx context.Context, id string) error {
+	_, err := s.db.NewDelete().Model((*webhooks.Worker)(nil)).Where("id = ?", id).Exec(ctx)
+	return errors.Wrap(err, "deregistering worker")
+}
+
+func (s Store) PruneWorkers(ctx context.Context, silentFor time.Duration) (int64, error) {
+	res, err := s.db.NewDelete().Model((*webhooks.Worker)(nil)).
+		Where("last_heartbeat_at < ?", time.Now().UTC().Add(-silentFor)).
+		Exec(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "pruning workers")
+	}
+	pruned, err := res.RowsAffected()
+	return pruned, errors.Wrap(err, "reading pruned worker count")
+}
+
+// ListWorkers returns the registered workers, oldest first, with the number
+// of deliveries each one currently holds a claim on.
+func (s Store) ListWorkers(ctx context.Context) ([]webhooks.Worker, error) {
+	res := []webhooks.Worker{}
+	err := s.db.NewSelect().Model(&res).
+		ColumnExpr("worker.*").
+		ColumnExpr(`(
+			SELECT COUNT(*) FROM deliveries d WHERE d.status = ? AND d.claimed_by = worker.id
+		) AS in_flight`, webhooks.StatusDeliveryDelivering).
+		Order("started_at", "id").
+		Scan(ctx)
+	return res, errors.Wrap(err, "listing workers")
+}
@@ -9,34 +9,80 @@ import (
 )
 
 var (
-	ErrConfigNotFound        = errors.New("config not found")
-	ErrConfigNotModified     = errors.New("config not modified")
-	ErrDeliveryNotFound      = errors.New("delivery not found")
-	ErrDeliveryNotReplayable = errors.New("delivery cannot be replayed")
-	ErrIdempotencyConflict   = errors.New("idempotency key already used with another request")
+	ErrConfigNotFound           = errors.New("config not found")
+	ErrConfigNotModified        = errors.New("config not modified")
+	ErrDeliveryNotFound         = errors.New("delivery not found")
+	ErrDeliveryNotReplayable    = errors.New("delivery cannot be replayed")
+	ErrDeliveryNotReschedulable = errors.New("delivery cannot be rescheduled")
+	ErrDeliveryNotCancellable   = errors.New("delivery cannot be cancelled")
+	ErrIdempotencyConflict      = errors.New("idempotency key already used with another request")
+
+	// ErrDeliveryCancelled reports that a delivery was cancelled while its
+	// attempt was in flight: the attempt is recorded, the delivery stays
+	// cancelled.
+	ErrDeliveryCancelled = errors.New("delivery was cancelled during its attempt")
 )
 
 type Store interface {
 	FindManyConfigs(ctx context.Context, filter map[string]any) ([]webhooks.Config, error)
 	InsertOneConfig(ctx context.Context, cfg webhooks.ConfigUser) (webhooks.Config, error)
-	DeleteOneConfig(ctx context.Context, id string) error
+	DeleteOneConfig(ctx context.Context, id, mode string) error
+	FinalizeDrainedConfigs(ctx context.Context) (int64, error)
 	UpdateOneConfigActivation(ctx context.Context, id string, active bool) (webhooks.Config, error)
+	PauseOneConfig(ctx context.Context, id string, resumeAt *time.Time) (webhooks.Config, error)
+	ResumeOneConfig(ctx context.Context, id string) (webhooks.Config, error)
+	ResumeDueConfigs(ctx context.Context) (int64, error)
+	RefreshMaintenanceWindows(ctx context.Context) (int64, error)
 	UpdateOneConfigSecret(ctx context.Context, id, secret string) (webhooks.Config, error)
+	// DisableOneConfig deactivates or pauses a persistently failing config.
+	DisableOneConfig(ctx context.Context, id, action, reason string) (webhooks.Config, error)
+	CountFinishedDeliveries(ctx context.Context, configID string, since time.Time) (failed, total int64, err error)
 	Close(ctx context.Context) error
 	UpdateOneConfig(ctx context.Context, id string, cfg webhooks.ConfigUser) error
+	ImportConfigs(ctx context.Context, configs []webhooks.Config, deliveries []webhooks.Delivery) (webhooks.ConfigImportResult, error)
 
-	EnqueueEvent(ctx context.Context, eventID, idempotencyKey, eventType, payload string, createdAt time.Time) error
-	ClaimDeliveries(ctx context.Context, limit int) ([]webhooks.Delivery, error)
-	CompleteDelivery(ctx context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (string, error)
+	EnqueueEvent(ctx context.Context, eventID, idempotencyKey, eventType, payload string, createdAt, deliverAt time.Time) (webhooks.EnqueuedEvent, error)
+	// ListenDeliveriesDue blocks until ctx is done, calling notify each time
+	// enqueued, replayed or rescheduled deliveries become due.
+	ListenDeliveriesDue(ctx context.Context, notify func()) error
+	ClaimDeliveries(ctx context.Context, limit int, lease webhooks.ClaimLease) ([]webhooks.Delivery, error)
+	// ExtendDeliveryLeases renews the leases the worker still holds on the
+	// given deliveries and returns how many it renewed.
+	ExtendDeliveryLeases(ctx context.Context, lease webhooks.ClaimLease, ids []string) (int64, error)
+	// CompleteDelivery records an attempt and its outcome, counting the
+	// consecutive failures of the config.
+	CompleteDelivery(ctx context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (webhooks.DeliveryCompletion, error)
 	FailClaimedDelivery(ctx context.Context, id string, claimedAt time.Time, reason string) error
+	ReleaseClaimedDelivery(ctx context.Context, id string, claimedAt, nextAttemptAt time.Time, postponed time.Duration) error
 	CancelDelivery(ctx context.Context, id string) error
-	RecoverStaleDeliveries(ctx context.Context, staleDuration time.Duration) (int64, error)
-	CountPendingDeliveries(ctx context.Context) (int64, error)
+	RecoverStaleDeliveries(ctx context.Context) (int64, error)
+	CountPendingDeliveries(ctx context.Context) (map[string]int64, error)
+	CountPendingDeliveriesByConfig(ctx context.Context, ids []string) (map[string]int64, error)
+	OldestDueDeliveryAge(ctx context.Context) (time.Duration, error)
+	FindMonitoredConfigIDs(ctx context.Context, limit int) ([]string, error)
+	FindBusiestConfigIDs(ctx context.Context, limit int) ([]string, error)
 	FindDeliveries(ctx context.Context, filter webhooks.DeliveryFilter) (webhooks.DeliveryPage, error)
 	GetDelivery(ctx context.Context, id string) (webhooks.Delivery, error)
 	FindDeliveryAttempts(ctx context.Context, deliveryID string, after *webhooks.DeliveryCursor, pageSize int) ([]webhooks.DeliveryAttempt, *webhooks.DeliveryCursor, error)
+	// FindDeliveryEvents returns the events after filter.After in feed order,
+	// leaving out the events that a running transaction could still precede.
+	FindDeliveryEvents(ctx context.Context, filter webhooks.DeliveryEventFilter) ([]webhooks.DeliveryEvent, error)
+	DeliveryEventsHead(ctx context.Context) (webhooks.DeliveryEventPosition, error)
 	ReplayDelivery(ctx context.Context, id, idempotencyKey string) (webhooks.Delivery, bool, error)
+	ReplayDeliveryToConfig(ctx context.Context, id, targetConfigID, idempotencyKey string) (webhooks.Delivery, bool, error)
+	BackfillConfig(ctx context.Context, configID string, request webhooks.BackfillConfigRequest, idempotencyKey string) (webhooks.BackfillConfigResult, bool, error)
 	ReplayDeliveries(ctx context.Context, request webhooks.ReplayDeliveriesRequest, idempotencyKey string) (webhooks.ReplayDeliveriesResult, bool, error)
+	RescheduleDelivery(ctx context.Context, id string, request webhooks.RescheduleDeliveryRequest, idempotencyKey string) (webhooks.Delivery, bool, error)
+	CancelOneDelivery(ctx context.Context, id string, cancellation webhooks.DeliveryCancellation, idempotencyKey string) (webhooks.Delivery, bool, error)
+	CancelDeliveries(ctx context.Context, request webhooks.CancelDeliveriesRequest, cancellation webhooks.DeliveryCancellation, idempotencyKey string) (webhooks.CancelDeliveriesResult, bool, error)
 	PurgeFinishedDeliveries(ctx context.Context, successOlderThan, failedOlderThan time.Duration, batchSize int) (int64, error)
 	BackfillDeliveries(ctx context.Context, successSince, failedSince time.Duration, batchSize int) (int64, error)
+
+	// RegisterWorker records the worker, or its heartbeat when it is already
+	// registered.
+	RegisterWorker(ctx context.Context, worker webhooks.Worker) error
+	DeregisterWorker(ctx context.Context, id string) error
+	// PruneWorkers forgets workers without a heartbeat for silentFor.
+	PruneWorkers(ctx context.Context, silentFor time.Duration) (int64, error)
+	ListWorkers(ctx context.Context) ([]webhooks.Worker, error)
 }
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/logging"
+	"github.com/formancehq/webhooks/pkg/metrics"
+	"github.com/pkg/errors"
+)
+
+// Per-config metrics modes.
+const (
+	ConfigMetricsMonitored = "monitored"
+	ConfigMetricsTop       = "top"
+
+	defaultConfigMetricsLimit  = 50
+	defaultConfigMetricsPeriod = time.Minute
+	// configMetricsAdmissionFactor bounds the configs ever labelled to this
+	// many times the limit: metric SDKs keep every attribute set recorded
+	// until the process exits, so a selection changing with the backlog would
+	// otherwise grow the exported series without bound.
+	configMetricsAdmissionFactor = 4
+)
+
+var ErrInvalidConfigMetricsMode = errors.New("config metrics mode should be monitored or top")
+
+// ConfigMetricsConfig selects the configs whose deliveries are labelled with
+// their config ID in metrics.
+type ConfigMetricsConfig struct {
+	// Mode is ConfigMetricsMonitored to label the configs with the monitor
+	// flag, ConfigMetricsTop to label the most backlogged configs, or empty to
+	// disable per-config metrics.
+	Mode string
+	// Limit caps the number of labelled configs.
+	Limit int
+	// Period between refreshes of the labelled configs.
+	Period time.Duration
+}
+
+func (c ConfigMetricsConfig) Enabled() bool {
+	return c.Mode != ""
+}
+
+func (c ConfigMetricsConfig) Validate() error {
+	switch c.Mode {
+	case "", ConfigMetricsMonitored, ConfigMetricsTop:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidConfigMetricsMode, c.Mode)
+}
+
+// ConfigMetrics periodically refreshes the set of labelled configs.
+type ConfigMetrics struct {
+	store  configMetricsStore
+	cfg    ConfigMetricsConfig
+	doneCh chan struct{}
+	// admitted holds every config labelled since start.
+	admitted map[string]struct{}
+}
+
+type configMetricsStore interface {
+	FindMonitoredConfigIDs(ctx context.Context, limit int) ([]string, error)
+	FindBusiestConfigIDs(ctx context.Context, limit int) ([]string, error)
+}
+
+func NewConfigMetrics(store configMetricsStore, cfg ConfigMetricsConfig) *ConfigMetrics {
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultConfigMetricsLimit
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = defaultConfigMetricsPeriod
+	}
+	return &ConfigMetrics{
+		store:    store,
+		cfg:      cfg,
+		doneCh:   make(chan struct{}),
+		admitted: map[string]struct{}{},
+	}
+}
+
+func (m *ConfigMetrics) Run(ctx context.Context) {
+	defer close(m.doneCh)
+
+	ticker := time.NewTicker(m.cfg.Period)
+	defer ticker.Stop()
+
+	m.refresh(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.refresh(ctx)
+		}
+	}
+}
+
+// refresh replaces the labelled configs. On error, the previous set is kept.
+func (m *ConfigMetrics) refresh(ctx context.Context) {
+	var (
+		ids []string
+		err error
+	)
+	switch m.cfg.Mode {
+	case ConfigMetricsMonitored:
+		ids, err = m.store.FindMonitoredConfigIDs(ctx, m.cfg.Limit)
+	case ConfigMetricsTop:
+		ids, err = m.store.FindBusiestConfigIDs(ctx, m.cfg.Limit)
+	default:
+		return
+	}
+	if err != nil {
+		logging.FromContext(ctx).Errorf("config metrics: selecting configs: %s", err)
+		return
+	}
+	admitted, refused := m.admit(ids)
+	for _, id := range refused {
+		logging.FromContext(ctx).Infof("config metrics: not labelling config %s: %d configs were already labelled since start", id, len(m.admitted))
+	}
+	metrics.RecordRefusedLabelledConfigs(ctx, len(refused))
+	metrics.SetLabelledConfigs(admitted)
+}
+
+// admit filters ids down to the configs already labelled once, and new ones
+// while fewer than the admission cap were ever labelled. It returns the new
+// configs refused past the cap apart.
+func (m *ConfigMetrics) admit(ids []string) (admitted, refused []string) {
+	admitted = make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := m.admitted[id]; !ok {
+			if len(m.admitted) >= m.cfg.Limit*configMetricsAdmissionFactor {
+				refused = append(refused, id)
+				continue
+			}
+			m.admitted[id] = struct{}{}
+		}
+		admitted = append(admitted, id)
+	}
+	return admitted, refused
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/formancehq/webhooks/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type configMetricsTestStore struct {
+	monitored []string
+	busiest   []string
+	err       error
+	limit     int
+}
+
+func (s *configMetricsTestStore) FindMonitoredConfigIDs(_ context.Context, limit int) ([]string, error) {
+	s.limit = limit
+	return s.monitored, s.err
+}
+
+func (s *configMetricsTestStore) FindBusiestConfigIDs(_ context.Context, limit int) ([]string, error) {
+	s.limit = limit
+	return s.busiest, s.err
+}
+
+func TestConfigMetricsConfigValidate(t *testing.T) {
+	require.NoError(t, ConfigMetricsConfig{}.Validate())
+	require.NoError(t, ConfigMetricsConfig{Mode: ConfigMetricsMonitored}.Validate())
+	require.NoError(t, ConfigMetricsConfig{Mode: ConfigMetricsTop}.Validate())
+	require.ErrorIs(t, ConfigMetricsConfig{Mode: "all"}.Validate(), ErrInvalidConfigMetricsMode)
+	require.False(t, ConfigMetricsConfig{}.Enabled())
+}
+
+func TestConfigMetricsLabelsSelectedConfigs(t *testing.T) {
+	t.Cleanup(func() { metrics.SetLabelledConfigs(nil) })
+	store := &configMetricsTestStore{monitored: []string{"monitored"}, busiest: []string{"busy"}}
+
+	NewConfigMetrics(store, ConfigMetricsConfig{Mode: ConfigMetricsMonitored}).refresh(context.Background())
+	require.Equal(t, []string{"monitored"}, metrics.LabelledConfigs())
+	require.Equal(t, defaultConfigMetricsLimit, store.limit)
+
+	NewConfigMetrics(store, ConfigMetricsConfig{Mode: ConfigMetricsTop, Limit: 3}).refresh(context.Background())
+	require.Equal(t, []string{"busy"}, metrics.LabelledConfigs())
+	require.Equal(t, 3, store.limit)
+
+	// A failed refresh keeps the previous selection.
+	store.err = errors.New("unavailable")
+	NewConfigMetrics(store, ConfigMetricsConfig{Mode: ConfigMetricsTop}).refresh(context.Background())
+	require.Equal(t, []string{"busy"}, metrics.LabelledConfigs())
+}
+
+func TestConfigMetricsCapsTheConfigsEverLabelled(t *testing.T) {
+	t.Cleanup(func() { metrics.SetLabelledConfigs(nil) })
+	store := &configMetricsTestStore{}
+	configMetrics := NewConfigMetrics(store, ConfigMetricsConfig{Mode: ConfigMetricsTop, Limit: 1})
+
+	for i := range configMetricsAdmissionFactor {
+		store.busiest = []string{fmt.Sprintf("busy-%d", i)}
+		configMetrics.refresh(context.Background())
+		require.Equal(t, store.busiest, metrics.LabelledConfigs())
+	}
+	store.busiest = []string{"newcomer"}
+	configMetrics.refresh(context.Background())
+	require.Empty(t, metrics.LabelledConfigs(), "no config can be admitted past the cap")
+	admitted, refused := configMetrics.admit([]string{"busy-1", "newcomer"})
+	require.Equal(t, []string{"busy-1"}, admitted)
+	require.Equal(t, []string{"newcomer"}, refused, "configs refused past the cap must be reported")
+	store.busiest = []string{"busy-0"}
+	configMetrics.refresh(context.Background())
+	require.Equal(t, []string{"busy-0"}, metrics.LabelledConfigs(), "admitted configs can come back")
+}
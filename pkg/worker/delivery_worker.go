@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -14,43 +16,167 @@ import (
 	"github.com/formancehq/go-libs/v2/publish"
 	webhooks "github.com/formancehq/webhooks/pkg"
 	"github.com/formancehq/webhooks/pkg/metrics"
+	"github.com/formancehq/webhooks/pkg/storage"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	defaultDeliveryHTTPTimeout = 30 * time.Second
-	staleRecoveryInterval      = time.Minute
-	staleDeliveryClaimAge      = 5 * time.Minute
+	defaultDeliveryHTTPTimeout   = 30 * time.Second
+	defaultClaimRecoveryInterval = 30 * time.Second
+	// silentWorkerRetention keeps crashed workers visible in the registry for
+	// a while after their last heartbeat.
+	silentWorkerRetention = time.Hour
+	drainProgressInterval = 2 * time.Second
+	// claimCoalesceDelay is how long freed workers wait for others before
+	// claiming fewer deliveries than minClaimSize.
+	claimCoalesceDelay = 50 * time.Millisecond
+	// stalledLoopPeriods is how many polling periods the dispatch loop may
+	// spend in one iteration before the worker reports not ready.
+	stalledLoopPeriods = 5
+
+	// deliverAtMetadataKey lets a publisher delay delivery without touching
+	// the event body; a top-level deliverAt field in the body works as well.
+	deliverAtMetadataKey = "deliverAt"
+)
+
+// MinClaimLeaseDuration is the shortest lease a dispatcher may hold claims
+// with: heartbeats, every third of it, must stay well apart.
+const MinClaimLeaseDuration = 3 * time.Second
+
+var (
+	errDispatcherStopped  = errors.New("dispatcher is not running")
+	errDispatcherDraining = errors.New("dispatcher is draining")
+	errDispatcherStalled  = errors.New("dispatch loop is stalled")
+	// errInvalidDeliverAt rejects an event rather than deliver it at a time
+	// its publisher did not ask for.
+	errInvalidDeliverAt = errors.New("deliverAt must be an RFC3339 timestamp")
+
+	ErrInvalidClaimLease = fmt.Errorf("claim lease duration should be at least %s and longer than the %s delivery timeout",
+		MinClaimLeaseDuration, defaultDeliveryHTTPTimeout)
 )
 
+// ClaimConfig configures how the dispatcher holds its claims.
+type ClaimConfig struct {
+	// WorkerID is recorded on every claim. It defaults to NewWorkerID().
+	WorkerID string
+	// LeaseDuration is how long a claim survives without a heartbeat. The
+	// dispatcher heartbeats its in-flight claims every third of it.
+	LeaseDuration time.Duration
+	// RecoveryInterval is the period between recoveries of expired claims.
+	RecoveryInterval time.Duration
+	// Version is reported in the workers registry.
+	Version string
+	// DrainTimeout is how long in-flight deliveries may run once the
+	// dispatcher stops, before they are aborted and handed back to the queue.
+	// Zero aborts them right away.
+	DrainTimeout time.Duration
+}
+
+// Validate rejects a lease that could expire while an attempt is still
+// running, or too short to heartbeat. Zero keeps the default lease.
+func (c ClaimConfig) Validate() error {
+	if c.LeaseDuration == 0 {
+		return nil
+	}
+	if c.LeaseDuration < MinClaimLeaseDuration || c.LeaseDuration <= defaultDeliveryHTTPTimeout {
+		return fmt.Errorf("%w: %s", ErrInvalidClaimLease, c.LeaseDuration)
+	}
+	return nil
+}
+
+// NewWorkerID returns an identity unique to this process, readable enough to
+// tell which host holds a claim.
+func NewWorkerID() string {
+	return fmt.Sprintf("%s-%s", hostname(), uuid.NewString()[:8])
+}
+
+func hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "worker"
+	}
+	return hostname
+}
+
+// DeliveryDispatcher sends claimed deliveries through a pool of batchSize
+// workers. It claims as many deliveries as the pool has free workers, and
+// claims again each time one frees up, so a slow endpoint only holds the
+// worker sending to it.
 type DeliveryDispatcher struct {
 	store       deliveryDispatchStore
 	httpClient  *http.Client
 	period      time.Duration
 	retryPolicy webhooks.BackoffPolicy
 	batchSize   int
+	minClaim    int
 	pool        *pond.WorkerPool
+	lease       webhooks.ClaimLease
+	recovery    time.Duration
+	drain       time.Duration
+	info        webhooks.Worker
+	failures    *FailurePolicy
+
+	// inFlight counts claimed deliveries not yet finished, pending lets
+	// callers wait for them, and freed is signalled each time one finishes.
+	inFlight atomic.Int64
+	pending  sync.WaitGroup
+	freed    chan struct{}
+	// draining is set once the dispatcher stopped claiming: deliveries not
+	// started yet are handed back instead of sent.
+	draining atomic.Bool
+	running  atomic.Bool
+	// loopedAt is when the dispatch loop last started an iteration, in Unix
+	// nanoseconds. The ticker wakes it at least every period.
+	loopedAt atomic.Int64
+
+	// claimedMu guards claimed, the IDs of the in-flight deliveries whose
+	// leases the heartbeat extends, and the last heartbeat time.
+	claimedMu       sync.Mutex
+	claimed         map[string]struct{}
+	lastHeartbeatAt time.Time
 }
 
 type deliveryEnqueuer interface {
-	EnqueueEvent(ctx context.Context, eventID, idempotencyKey, eventType, payload string, createdAt time.Time) error
+	EnqueueEvent(ctx context.Context, eventID, idempotencyKey, eventType, payload string, createdAt, deliverAt time.Time) (webhooks.EnqueuedEvent, error)
 }
 
 type deliveryDispatchStore interface {
+	ListenDeliveriesDue(ctx context.Context, notify func()) error
 	FindManyConfigs(ctx context.Context, filter map[string]any) ([]webhooks.Config, error)
-	ClaimDeliveries(ctx context.Context, limit int) ([]webhooks.Delivery, error)
-	CompleteDelivery(ctx context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (string, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease webhooks.ClaimLease) ([]webhooks.Delivery, error)
+	ExtendDeliveryLeases(ctx context.Context, lease webhooks.ClaimLease, ids []string) (int64, error)
+	CompleteDelivery(ctx context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (webhooks.DeliveryCompletion, error)
 	FailClaimedDelivery(ctx context.Context, id string, claimedAt time.Time, reason string) error
+	ReleaseClaimedDelivery(ctx context.Context, id string, claimedAt, nextAttemptAt time.Time, postponed time.Duration) error
 	CancelDelivery(ctx context.Context, id string) error
-	RecoverStaleDeliveries(ctx context.Context, staleDuration time.Duration) (int64, error)
+	RecoverStaleDeliveries(ctx context.Context) (int64, error)
+	RegisterWorker(ctx context.Context, worker webhooks.Worker) error
+	DeregisterWorker(ctx context.Context, id string) error
+	PruneWorkers(ctx context.Context, silentFor time.Duration) (int64, error)
+	FinalizeDrainedConfigs(ctx context.Context) (int64, error)
+	ResumeDueConfigs(ctx context.Context) (int64, error)
+	RefreshMaintenanceWindows(ctx context.Context) (int64, error)
 }
 
-func NewDeliveryDispatcher(store deliveryDispatchStore, httpClient *http.Client, period time.Duration, retryPolicy webhooks.BackoffPolicy, batchSize int) *DeliveryDispatcher {
+func NewDeliveryDispatcher(store deliveryDispatchStore, httpClient *http.Client, period time.Duration, retryPolicy webhooks.BackoffPolicy, batchSize int, claims ClaimConfig) *DeliveryDispatcher {
+	if period <= 0 {
+		period = 3 * time.Second
+	}
 	if batchSize <= 0 {
 		batchSize = 50
 	}
+	if claims.WorkerID == "" {
+		claims.WorkerID = NewWorkerID()
+	}
+	if claims.LeaseDuration <= 0 {
+		claims.LeaseDuration = webhooks.DefaultClaimLeaseDuration
+	}
+	if claims.RecoveryInterval <= 0 {
+		claims.RecoveryInterval = defaultClaimRecoveryInterval
+	}
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: defaultDeliveryHTTPTimeout}
 	} else if httpClient.Timeout <= 0 {
@@ -60,33 +186,154 @@ func NewDeliveryDispatcher(store deliveryDispatchStore, httpClient *http.Client,
 	}
 	return &DeliveryDispatcher{
 		store: store, httpClient: httpClient, period: period, retryPolicy: retryPolicy,
-		batchSize: batchSize, pool: pond.New(batchSize, batchSize),
+		batchSize: batchSize, minClaim: minClaimSize(batchSize),
+		pool: pond.New(batchSize, batchSize), freed: make(chan struct{}, 1),
+		lease:    webhooks.ClaimLease{WorkerID: claims.WorkerID, Duration: claims.LeaseDuration},
+		recovery: claims.RecoveryInterval, drain: claims.DrainTimeout, claimed: map[string]struct{}{},
+		info: webhooks.Worker{
+			ID: claims.WorkerID, Hostname: hostname(), Version: claims.Version, StartedAt: time.Now().UTC(),
+		},
 	}
 }
 
+// SetFailurePolicy makes the dispatcher report the completion of its
+// deliveries to policy. It must be called before Run.
+func (d *DeliveryDispatcher) SetFailurePolicy(policy *FailurePolicy) {
+	d.failures = policy
+}
+
+// minClaimSize is the fewest free workers the dispatcher claims for as soon as
+// they free up. Claiming for each worker as it frees up would cost a claim
+// query per delivery and, with one or two slots at a time, give every slot to
+// the high lane: a batch of at least one slot per lane keeps the low lane
+// moving under load. Fewer free workers claim after claimCoalesceDelay.
+func minClaimSize(batchSize int) int {
+	return min(batchSize, max(len(webhooks.Priorities), batchSize/4))
+}
+
+// Info is the local view of the worker: its registration and the number of
+// deliveries it is sending.
+func (d *DeliveryDispatcher) Info() webhooks.Worker {
+	info := d.info
+	d.claimedMu.Lock()
+	info.LastHeartbeatAt = d.lastHeartbeatAt
+	d.claimedMu.Unlock()
+	info.InFlight = d.inFlight.Load()
+	info.Draining = d.draining.Load()
+	return info
+}
+
+// Run claims and sends deliveries until ctx is done, then drains: in-flight
+// deliveries get the drain timeout to finish on their own context.
 func (d *DeliveryDispatcher) Run(ctx context.Context) {
-	if d.period <= 0 {
-		d.period = 3 * time.Second
-	}
+	sendCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+	d.loopedAt.Store(time.Now().UnixNano())
+	d.running.Store(true)
+	defer d.running.Store(false)
 	ticker := time.NewTicker(d.period)
-	recoveryTicker := time.NewTicker(staleRecoveryInterval)
+	recoveryTicker := time.NewTicker(d.recovery)
 	defer ticker.Stop()
 	defer recoveryTicker.Stop()
 
-	d.dispatch(ctx)
+	// Notifications wake the dispatcher as soon as work is enqueued. They are
+	// coalesced while a dispatch runs; the ticker still covers retries coming
+	// due and notifications lost while the listener reconnects.
+	wake := make(chan struct{}, 1)
+	listening := make(chan struct{})
+	go func() {
+		defer close(listening)
+		err := d.store.ListenDeliveriesDue(ctx, func() {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			logging.FromContext(ctx).Errorf("listening for due deliveries, falling back to polling: %s", err)
+		}
+	}()
+	defer func() { <-listening }()
+
+	d.register(ctx)
+	defer func() {
+		deregisterCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := d.store.DeregisterWorker(deregisterCtx, d.info.ID); err != nil {
+			logging.FromContext(ctx).Errorf("deregistering worker %s: %s", d.info.ID, err)
+		}
+	}()
+
+	// Heartbeats run on their own, through the drain too: a slow claim or
+	// recovery query must not let the leases of in-flight deliveries expire.
+	// They stop before the worker deregisters.
+	heartbeatCtx, stopHeartbeats := context.WithCancel(context.WithoutCancel(ctx))
+	heartbeating := make(chan struct{})
+	go func() {
+		defer close(heartbeating)
+		d.heartbeatLoop(heartbeatCtx)
+	}()
+	defer func() {
+		stopHeartbeats()
+		<-heartbeating
+	}()
+
+	// drained is set once a claim came back short: nothing else is due, so
+	// freed workers wait for the next tick or notification to claim again.
+	drained := d.tick(ctx, sendCtx)
+	// Below minClaim free workers, claims wait for more to free up, or for
+	// the coalescing delay.
+	coalesce := time.NewTimer(claimCoalesceDelay)
+	coalesce.Stop()
+	defer coalesce.Stop()
+	coalescing := false
+	claimSoon := func() {
+		if d.batchSize-int(d.inFlight.Load()) >= d.minClaim {
+			drained = d.dispatch(ctx, sendCtx)
+		} else if !coalescing {
+			coalescing = true
+			coalesce.Reset(claimCoalesceDelay)
+		}
+	}
 	for {
+		d.loopedAt.Store(time.Now().UnixNano())
 		select {
 		case <-ctx.Done():
-			d.pool.StopAndWait()
+			d.shutdown(sendCtx, abort)
 			return
 		case <-recoveryTicker.C:
-			if recovered, err := d.store.RecoverStaleDeliveries(ctx, staleDeliveryClaimAge); err != nil {
+			if recovered, err := d.store.RecoverStaleDeliveries(ctx); err != nil {
 				logging.FromContext(ctx).Errorf("recovering stale deliveries: %s", err)
 			} else if recovered > 0 {
 				metrics.RecordRecoveredClaims(ctx, recovered)
 			}
+			if _, err := d.store.PruneWorkers(ctx, silentWorkerRetention); err != nil {
+				logging.FromContext(ctx).Errorf("pruning silent workers: %s", err)
+			}
+			if finalized, err := d.store.FinalizeDrainedConfigs(ctx); err != nil {
+				logging.FromContext(ctx).Errorf("finalizing drained configs: %s", err)
+			} else if finalized > 0 {
+				logging.FromContext(ctx).Infof("deleted %d drained configs", finalized)
+			}
+			if resumed, err := d.store.ResumeDueConfigs(ctx); err != nil {
+				logging.FromContext(ctx).Errorf("resuming paused configs: %s", err)
+			} else if resumed > 0 {
+				logging.FromContext(ctx).Infof("resumed %d paused configs", resumed)
+			}
 		case <-ticker.C:
-			d.dispatch(ctx)
+			drained = d.tick(ctx, sendCtx)
+		case <-wake:
+			drained = false
+			claimSoon()
+		case <-d.freed:
+			if !drained {
+				claimSoon()
+			}
+		case <-coalesce.C:
+			coalescing = false
+			if !drained {
+				drained = d.dispatch(ctx, sendCtx)
+			}
 		}
 	}
 }
@@ -101,18 +348,175 @@ func limitRetryWindowBeforeAttempt(retryPolicy webhooks.BackoffPolicy, firstAtte
 	return nil
 }
 
-func (d *DeliveryDispatcher) dispatch(ctx context.Context) {
-	deliveries, err := d.store.ClaimDeliveries(ctx, d.batchSize)
+func (d *DeliveryDispatcher) tick(ctx, sendCtx context.Context) bool {
+	if _, err := d.store.RefreshMaintenanceWindows(ctx); err != nil {
+		logging.FromContext(ctx).Errorf("refreshing maintenance windows: %s", err)
+	}
+	return d.dispatch(ctx, sendCtx)
+}
+
+// dispatch claims one delivery per free worker and hands them over without
+// waiting for them; they are sent with sendCtx, which outlives ctx during a
+// drain. It reports whether the claim came back short.
+func (d *DeliveryDispatcher) dispatch(ctx, sendCtx context.Context) bool {
+	free := d.batchSize - int(d.inFlight.Load())
+	if free <= 0 {
+		return false
+	}
+	deliveries, err := d.store.ClaimDeliveries(ctx, free, d.lease)
 	if err != nil {
 		logging.FromContext(ctx).Errorf("claiming deliveries: %s", err)
-		return
+		return true
 	}
-	group := d.pool.Group()
 	for i := range deliveries {
 		delivery := deliveries[i]
-		group.Submit(func() { d.dispatchOne(ctx, delivery) })
+		d.inFlight.Add(1)
+		d.pending.Add(1)
+		d.claimedMu.Lock()
+		d.claimed[delivery.ID] = struct{}{}
+		d.claimedMu.Unlock()
+		d.pool.Submit(func() {
+			defer func() {
+				d.claimedMu.Lock()
+				delete(d.claimed, delivery.ID)
+				d.claimedMu.Unlock()
+				d.inFlight.Add(-1)
+				d.pending.Done()
+				select {
+				case d.freed <- struct{}{}:
+				default:
+				}
+			}()
+			if d.draining.Load() {
+				d.release(sendCtx, delivery)
+				return
+			}
+			d.dispatchOne(sendCtx, delivery)
+		})
 	}
-	group.Wait()
+	return len(deliveries) < free
+}
+
+// shutdown waits for the in-flight deliveries until the drain timeout, then
+// aborts the remaining ones, which hand their claims back. Leases keep being
+// extended meanwhile.
+func (d *DeliveryDispatcher) shutdown(ctx context.Context, abort context.CancelFunc) {
+	logger := logging.FromContext(ctx)
+	d.draining.Store(true)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		d.pool.StopAndWait()
+	}()
+	if d.drain <= 0 {
+		abort()
+	} else {
+		logger.Infof("draining %d in-flight deliveries for up to %s", d.inFlight.Load(), d.drain)
+	}
+	deadline := time.NewTimer(d.drain)
+	progress := time.NewTicker(drainProgressInterval)
+	defer deadline.Stop()
+	defer progress.Stop()
+	for {
+		select {
+		case <-stopped:
+			if d.failures != nil {
+				d.failures.Wait()
+			}
+			logger.Infof("dispatcher drained")
+			return
+		case <-deadline.C:
+			if d.drain > 0 {
+				logger.Infof("drain timed out, aborting %d in-flight deliveries", d.inFlight.Load())
+			}
+			abort()
+		case <-progress.C:
+			logger.Infof("draining, %d deliveries in flight", d.inFlight.Load())
+		}
+	}
+}
+
+// release hands a claimed delivery back to the queue, due now, without
+// recording an attempt.
+func (d *DeliveryDispatcher) release(ctx context.Context, delivery webhooks.Delivery) {
+	if delivery.ClaimedAt == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := d.store.ReleaseClaimedDelivery(ctx, delivery.ID, *delivery.ClaimedAt, time.Now().UTC(), 0); err != nil {
+		logging.FromContext(ctx).Errorf("releasing delivery %s: %s", delivery.ID, err)
+	}
+}
+
+// Ready fails unless the dispatch loop runs, is not draining, and started an
+// iteration within the last few polling periods: a loop blocked on a claim or
+// recovery query is not dispatching anything.
+func (d *DeliveryDispatcher) Ready(context.Context) error {
+	switch {
+	case d.draining.Load():
+		return errDispatcherDraining
+	case !d.running.Load():
+		return errDispatcherStopped
+	}
+	if since := time.Since(time.Unix(0, d.loopedAt.Load())); since > stalledLoopPeriods*d.period {
+		return fmt.Errorf("%w: no iteration for %s", errDispatcherStalled, since.Truncate(time.Millisecond))
+	}
+	return nil
+}
+
+// register records the worker in the registry, or refreshes its heartbeat.
+func (d *DeliveryDispatcher) register(ctx context.Context) {
+	if err := d.store.RegisterWorker(ctx, d.info); err != nil {
+		logging.FromContext(ctx).Errorf("registering worker %s: %s", d.info.ID, err)
+		return
+	}
+	d.claimedMu.Lock()
+	d.lastHeartbeatAt = time.Now().UTC()
+	d.claimedMu.Unlock()
+}
+
+// heartbeatLoop heartbeats every third of the lease until ctx is done.
+func (d *DeliveryDispatcher) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(d.lease.Duration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.heartbeat(ctx)
+		}
+	}
+}
+
+// heartbeat extends the leases of the in-flight deliveries, so that a slow
+// endpoint does not get its delivery recovered and sent twice.
+func (d *DeliveryDispatcher) heartbeat(ctx context.Context) {
+	d.register(ctx)
+	d.claimedMu.Lock()
+	ids := make([]string, 0, len(d.claimed))
+	for id := range d.claimed {
+		ids = append(ids, id)
+	}
+	d.claimedMu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+	extended, err := d.store.ExtendDeliveryLeases(ctx, d.lease, ids)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("extending delivery leases: %s", err)
+		return
+	}
+	if extended < int64(len(ids)) {
+		// Those finished meanwhile or expired and were recovered; in the
+		// latter case their completion is rejected.
+		logging.FromContext(ctx).Debugf("extended %d of %d delivery leases", extended, len(ids))
+	}
+}
+
+// wait blocks until every dispatched delivery is finished.
+func (d *DeliveryDispatcher) wait() {
+	d.pending.Wait()
 }
 
 func (d *DeliveryDispatcher) dispatchOne(ctx context.Context, delivery webhooks.Delivery) {
@@ -136,6 +540,15 @@ func (d *DeliveryDispatcher) dispatchOne(ctx context.Context, delivery webhooks.
 	}
 
 	now := time.Now().UTC()
+	if end, ok := configs[0].MaintenanceEnd(now); ok && delivery.ClaimedAt != nil {
+		// Claimed right as a maintenance window started: postpone without an
+		// attempt, and without charging the wait to the retry window.
+		if err := d.store.ReleaseClaimedDelivery(ctx, delivery.ID, *delivery.ClaimedAt, end, end.Sub(now)); err != nil {
+			logging.FromContext(ctx).Errorf("postponing delivery %s after maintenance: %s", delivery.ID, err)
+			span.RecordError(err)
+		}
+		return
+	}
 	var preflightErr error
 	if delivery.AttemptCount > 0 {
 		if limiter, ok := d.retryPolicy.(webhooks.RetryAttemptLimiter); ok {
@@ -163,12 +576,24 @@ func (d *DeliveryDispatcher) dispatchOne(ctx context.Context, delivery webhooks.
 	}
 	attemptResult, err := webhooks.MakeAttempt(ctx, d.httpClient, d.retryPolicy, uuid.NewString(),
 		delivery.ID, delivery.AttemptCount, configs[0], delivery.IdempotencyKey,
-		[]byte(delivery.Payload), false, webhooks.WithFirstAttemptAt(*delivery.CycleStartedAt))
+		[]byte(delivery.Payload), false, webhooks.WithFirstAttemptAt(*delivery.CycleStartedAt),
+		webhooks.WithCapture(configs[0].CaptureAttempts))
 	if err != nil {
 		logging.FromContext(ctx).Errorf("sending delivery %s: %s", delivery.ID, err)
 		span.RecordError(err)
 		return
 	}
+	if ctx.Err() != nil && attemptResult.StatusCode == 0 {
+		// Aborted by the end of a drain rather than failed by the endpoint.
+		d.release(ctx, delivery)
+		return
+	}
+	metrics.RecordConfigDelivery(ctx, delivery.ConfigID, attemptResult.Status, attemptResult.Duration)
+	// Replays are operator decisions and would skew the latency from the event.
+	measureLag := delivery.ReplayGeneration == 0
+	if measureLag && delivery.AttemptCount == 0 {
+		metrics.RecordFirstAttemptDelay(ctx, delivery.Priority, now.Sub(delivery.DueAt()))
+	}
 
 	completedAt := time.Now().UTC()
 	delivery.AttemptCount++
@@ -189,6 +614,13 @@ func (d *DeliveryDispatcher) dispatchOne(ctx context.Context, delivery webhooks.
 	case webhooks.StatusAttemptToRetry:
 		delivery.Status = webhooks.StatusDeliveryPending
 		delivery.NextAttemptAt = &attemptResult.NextRetryAfter
+		if end, ok := configs[0].MaintenanceEnd(attemptResult.NextRetryAfter); ok {
+			// Like a pause, the window must not use up the retry window:
+			// shift the cycle by the postponement.
+			delivery.NextAttemptAt = &end
+			cycleStartedAt := delivery.CycleStartedAt.Add(end.Sub(attemptResult.NextRetryAfter))
+			delivery.CycleStartedAt = &cycleStartedAt
+		}
 		outcome = webhooks.OutcomeDeliveryRetryableFailure
 	default:
 		delivery.Status = webhooks.StatusDeliveryFailed
@@ -198,18 +630,29 @@ func (d *DeliveryDispatcher) dispatchOne(ctx context.Context, delivery webhooks.
 		ID: uuid.NewString(), DeliveryID: delivery.ID, AttemptNumber: delivery.AttemptCount,
 		ReplayGeneration: delivery.ReplayGeneration, Endpoint: configs[0].Endpoint,
 		Outcome: outcome, StatusCode: attemptResult.StatusCode, Error: attemptResult.DeliveryError,
-		ResponseExcerpt: attemptResult.ResponseExcerpt,
-		CreatedAt:       completedAt,
+		ResponseExcerpt: attemptResult.ResponseExcerpt, Capture: attemptResult.Capture,
+		WorkerID: d.info.ID, CreatedAt: completedAt,
 	}
 	durationMillis := attemptResult.Duration.Milliseconds()
 	attempt.DurationMillis = &durationMillis
-	finalStatus, err := d.store.CompleteDelivery(ctx, delivery, attempt)
+	// The attempt happened: record it even if the drain is aborting.
+	completion, err := d.store.CompleteDelivery(context.WithoutCancel(ctx), delivery, attempt)
+	if errors.Is(err, storage.ErrDeliveryCancelled) {
+		logging.FromContext(ctx).Infof("delivery %s was cancelled during its attempt", delivery.ID)
+		return
+	}
 	if err != nil {
 		logging.FromContext(ctx).Errorf("completing delivery %s: %s", delivery.ID, err)
 		span.RecordError(err)
 		return
 	}
-	metrics.RecordDeliveryTransition(ctx, finalStatus, "normal", 1)
+	metrics.RecordDeliveryTransition(ctx, completion.Status, "normal", 1)
+	if d.failures != nil {
+		d.failures.Observe(context.WithoutCancel(ctx), configs[0], completion)
+	}
+	if measureLag && completion.Status == webhooks.StatusDeliverySucceeded {
+		metrics.RecordDeliveryLag(ctx, delivery.Priority, completedAt.Sub(delivery.DueAt()))
+	}
 }
 
 func processDeliveryMessages(store deliveryEnqueuer) func(msg *message.Message) error {
@@ -224,19 +667,20 @@ func processDeliveryMessages(store deliveryEnqueuer) func(msg *message.Message)
 		)
 		defer span.End()
 		ctx = context.WithoutCancel(ctx)
-		eventApp := strings.ToLower(event.App)
-		eventType := strings.ToLower(event.Type)
-		if eventApp == "" {
-			event.Type = eventType
-		} else {
-			event.Type = strings.Join([]string{eventApp, eventType}, ".")
-		}
+		event.Type = webhooks.NormalizeEventType(event.App, event.Type)
 		span.SetAttributes(attribute.String("event_type", event.Type))
 		payload, err := json.Marshal(event)
 		if err != nil {
 			return fmt.Errorf("marshal event: %w", err)
 		}
-		if err := store.EnqueueEvent(ctx, msg.UUID, event.IdempotencyKey, event.Type, string(payload), time.Now().UTC()); err != nil {
+		now := time.Now().UTC()
+		deliverAt, err := messageDeliverAt(msg)
+		if err != nil {
+			wrapped := fmt.Errorf("rejecting event %s: %w", msg.UUID, err)
+			span.RecordError(wrapped)
+			return wrapped
+		}
+		if _, err := store.EnqueueEvent(ctx, msg.UUID, event.IdempotencyKey, event.Type, string(payload), now, deliverAt); err != nil {
 			wrapped := fmt.Errorf("enqueue deliveries for event %s: %w", event.Type, err)
 			span.RecordError(wrapped)
 			return wrapped
@@ -244,3 +688,26 @@ func processDeliveryMessages(store deliveryEnqueuer) func(msg *message.Message)
 		return nil
 	}
 }
+
+// messageDeliverAt returns the time the event asked to be delivered at, or the
+// zero time when it did not. Metadata takes precedence over the body.
+func messageDeliverAt(msg *message.Message) (time.Time, error) {
+	value := msg.Metadata.Get(deliverAtMetadataKey)
+	if value == "" {
+		body := struct {
+			DeliverAt string `json:"deliverAt"`
+		}{}
+		if err := json.Unmarshal(msg.Payload, &body); err != nil {
+			return time.Time{}, nil
+		}
+		value = body.DeliverAt
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	deliverAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", errInvalidDeliverAt, err)
+	}
+	return deliverAt.UTC(), nil
+}
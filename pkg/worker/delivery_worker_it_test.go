@@ -5,6 +5,11 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,7 +26,7 @@ import (
 	"github.com/uptrace/bun"
 )
 
-func newWorkerIntegrationStore(t *testing.T) (storage.Store, *bun.DB) {
+func newWorkerIntegrationStore(t testing.TB) (storage.Store, *bun.DB) {
 	t.Helper()
 	server := pgtesting.CreatePostgresServer(t, docker.NewPool(t, logging.Testing()))
 	database := server.NewDatabase(t)
@@ -111,3 +116,70 @@ func TestDeliveryRouterAcknowledgesAfterPostgresCommitAndNacksOnRollback(t *test
 	require.NoError(t, err)
 	require.Equal(t, 1, deliveries, "the rolled-back delivery must not be persisted")
 }
+
+// claimCountingStore counts the claim queries the dispatcher runs.
+type claimCountingStore struct {
+	storage.Store
+	claims atomic.Int64
+}
+
+func (s *claimCountingStore) ClaimDeliveries(ctx context.Context, limit int, lease webhooks.ClaimLease) ([]webhooks.Delivery, error) {
+	s.claims.Add(1)
+	return s.Store.ClaimDeliveries(ctx, limit, lease)
+}
+
+// BenchmarkDeliveryDispatcherSlowEndpointPostgres is
+// BenchmarkDeliveryDispatcherSlowEndpoint against PostgreSQL, where each claim
+// costs one query per priority lane.
+func BenchmarkDeliveryDispatcherSlowEndpointPostgres(b *testing.B) {
+	const deliveries = 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.Header.Get("formance-webhook-id"), "0") {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	postgresStore, db := newWorkerIntegrationStore(b)
+	config, err := postgresStore.InsertOneConfig(ctx, webhooks.ConfigUser{
+		Endpoint: server.URL, Secret: webhooks.NewSecret(), EventTypes: []string{"test.event"},
+	})
+	require.NoError(b, err)
+	store := &claimCountingStore{Store: postgresStore}
+
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		now := time.Now().UTC()
+		batch := make([]webhooks.Delivery, 0, deliveries)
+		for j := 0; j < deliveries; j++ {
+			batch = append(batch, webhooks.Delivery{
+				ID: fmt.Sprintf("%d-%d", i, j), EventID: fmt.Sprintf("event-%d-%d", i, j), ConfigID: config.ID,
+				EventType: "test.event", Payload: `{}`, Status: webhooks.StatusDeliveryPending,
+				Priority: webhooks.Priorities[j%len(webhooks.Priorities)], NextAttemptAt: &now,
+				CreatedAt: now, UpdatedAt: now,
+			})
+		}
+		_, err := db.NewInsert().Model(&batch).Exec(ctx)
+		require.NoError(b, err)
+		dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 10, ClaimConfig{})
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			dispatcher.Run(runCtx)
+		}()
+		for {
+			var pending int
+			require.NoError(b, db.NewRaw("SELECT COUNT(*) FROM deliveries WHERE status <> ?",
+				webhooks.StatusDeliverySucceeded).Scan(ctx, &pending))
+			if pending == 0 {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		<-done
+	}
+	b.ReportMetric(float64(b.N*deliveries)/time.Since(start).Seconds(), "deliveries/s")
+	b.ReportMetric(float64(store.claims.Load())/float64(b.N*deliveries), "claims/delivery")
+}
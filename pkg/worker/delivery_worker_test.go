@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/formancehq/go-libs/v2/publish"
 	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/health"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 )
@@ -28,13 +31,29 @@ type deliveryMockStore struct {
 	cancelled      []string
 	failedClaims   []string
 	failureReasons []string
+	released       map[string]time.Time
+	postponed      map[string]time.Duration
 	findError      error
 	insertError    error
 	enqueueStarted chan struct{}
 	enqueueRelease chan struct{}
 	claimStarted   chan struct{}
+	claimBlocked   sync.Once
 	claimCancelled chan struct{}
 	claimRelease   chan struct{}
+	claims         chan struct{}
+	dueNotify      chan func()
+	heartbeats     [][]string
+	registered     []webhooks.Worker
+	deregistered   []string
+}
+
+func (m *deliveryMockStore) ListenDeliveriesDue(ctx context.Context, notify func()) error {
+	if m.dueNotify != nil {
+		m.dueNotify <- notify
+	}
+	<-ctx.Done()
+	return nil
 }
 
 type noRetryPolicy struct{}
@@ -50,23 +69,26 @@ func (m *deliveryMockStore) FindManyConfigs(context.Context, map[string]any) ([]
 	return m.configs, nil
 }
 
-func (m *deliveryMockStore) EnqueueEvent(_ context.Context, eventID, idempotencyKey, eventType, payload string, createdAt time.Time) error {
+func (m *deliveryMockStore) EnqueueEvent(_ context.Context, eventID, idempotencyKey, eventType, payload string, createdAt, deliverAt time.Time) (webhooks.EnqueuedEvent, error) {
 	if m.enqueueStarted != nil {
 		close(m.enqueueStarted)
 		<-m.enqueueRelease
 	}
 	if m.insertError != nil {
-		return m.insertError
+		return webhooks.EnqueuedEvent{}, m.insertError
 	}
 	for _, config := range m.configs {
 		nextAttemptAt := createdAt
+		if deliverAt.After(createdAt) {
+			nextAttemptAt = deliverAt
+		}
 		m.deliveries = append(m.deliveries, webhooks.Delivery{
 			EventID: eventID, IdempotencyKey: idempotencyKey, ConfigID: config.ID,
 			EventType: eventType, Payload: payload, Status: webhooks.StatusDeliveryPending,
 			NextAttemptAt: &nextAttemptAt, CreatedAt: createdAt,
 		})
 	}
-	return nil
+	return webhooks.EnqueuedEvent{EventID: eventID, EventType: eventType}, nil
 }
 
 type singleMessageSubscriber struct {
@@ -105,14 +127,21 @@ func runDeliveryRouter(t *testing.T, store deliveryEnqueuer) *singleMessageSubsc
 	return subscriber
 }
 
-func (m *deliveryMockStore) ClaimDeliveries(ctx context.Context, limit int) ([]webhooks.Delivery, error) {
+func (m *deliveryMockStore) ClaimDeliveries(ctx context.Context, limit int, _ webhooks.ClaimLease) ([]webhooks.Delivery, error) {
 	if m.claimStarted != nil {
-		close(m.claimStarted)
-		<-ctx.Done()
-		close(m.claimCancelled)
-		<-m.claimRelease
+		// Only the first claim blocks: a short period may tick once more
+		// before the cancelled loop returns.
+		m.claimBlocked.Do(func() {
+			close(m.claimStarted)
+			<-ctx.Done()
+			close(m.claimCancelled)
+			<-m.claimRelease
+		})
 		return nil, ctx.Err()
 	}
+	if m.claims != nil {
+		m.claims <- struct{}{}
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if len(m.claimed) > limit {
@@ -125,12 +154,12 @@ func (m *deliveryMockStore) ClaimDeliveries(ctx context.Context, limit int) ([]w
 	return result, nil
 }
 
-func (m *deliveryMockStore) CompleteDelivery(_ context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (string, error) {
+func (m *deliveryMockStore) CompleteDelivery(_ context.Context, delivery webhooks.Delivery, attempt webhooks.DeliveryAttempt) (webhooks.DeliveryCompletion, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.completed = append(m.completed, delivery)
 	m.attempts = append(m.attempts, attempt)
-	return delivery.Status, nil
+	return webhooks.DeliveryCompletion{Status: delivery.Status}, nil
 }
 
 func (m *deliveryMockStore) CancelDelivery(_ context.Context, id string) error {
@@ -147,10 +176,59 @@ func (m *deliveryMockStore) FailClaimedDelivery(_ context.Context, id string, _
 	m.failureReasons = append(m.failureReasons, reason)
 	return nil
 }
-func (m *deliveryMockStore) RecoverStaleDeliveries(context.Context, time.Duration) (int64, error) {
+func (m *deliveryMockStore) ExtendDeliveryLeases(_ context.Context, _ webhooks.ClaimLease, ids []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeats = append(m.heartbeats, ids)
+	return int64(len(ids)), nil
+}
+
+func (m *deliveryMockStore) RecoverStaleDeliveries(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *deliveryMockStore) RegisterWorker(_ context.Context, worker webhooks.Worker) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registered = append(m.registered, worker)
+	return nil
+}
+
+func (m *deliveryMockStore) DeregisterWorker(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deregistered = append(m.deregistered, id)
+	return nil
+}
+
+func (m *deliveryMockStore) PruneWorkers(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m *deliveryMockStore) FinalizeDrainedConfigs(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *deliveryMockStore) ResumeDueConfigs(context.Context) (int64, error) {
 	return 0, nil
 }
 
+func (m *deliveryMockStore) RefreshMaintenanceWindows(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *deliveryMockStore) ReleaseClaimedDelivery(_ context.Context, id string, _, nextAttemptAt time.Time, postponed time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.released == nil {
+		m.released = map[string]time.Time{}
+		m.postponed = map[string]time.Duration{}
+	}
+	m.released[id] = nextAttemptAt
+	m.postponed[id] = postponed
+	return nil
+}
+
 func TestProcessDeliveryMessagesPersistsBeforeAcknowledgement(t *testing.T) {
 	store := &deliveryMockStore{configs: []webhooks.Config{{
 		ConfigUser: webhooks.ConfigUser{Endpoint: "https://example.com", Secret: webhooks.NewSecret(), EventTypes: []string{"ledger.transaction.created"}},
@@ -171,6 +249,70 @@ func TestProcessDeliveryMessagesPersistsBeforeAcknowledgement(t *testing.T) {
 	require.Equal(t, "config-1", delivery.ConfigID)
 }
 
+func TestProcessDeliveryMessagesHonoursDeliverAt(t *testing.T) {
+	deliverAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	payload, err := json.Marshal(publish.EventMessage{Type: "test.event"})
+	require.NoError(t, err)
+
+	store := &deliveryMockStore{configs: []webhooks.Config{{ID: "config-1", Active: true}}}
+	msg := message.NewMessage("metadata-message", payload)
+	msg.Metadata.Set(deliverAtMetadataKey, deliverAt.Format(time.RFC3339))
+	require.NoError(t, processDeliveryMessages(store)(msg))
+	require.Len(t, store.deliveries, 1)
+	require.Equal(t, deliverAt, *store.deliveries[0].NextAttemptAt)
+
+	body, err := json.Marshal(map[string]any{"type": "test.event", "deliverAt": deliverAt.Format(time.RFC3339)})
+	require.NoError(t, err)
+	store = &deliveryMockStore{configs: []webhooks.Config{{ID: "config-1", Active: true}}}
+	require.NoError(t, processDeliveryMessages(store)(message.NewMessage("body-message", body)))
+	require.Len(t, store.deliveries, 1)
+	require.Equal(t, deliverAt, *store.deliveries[0].NextAttemptAt)
+
+}
+
+func TestProcessDeliveryMessagesRejectsMalformedDeliverAt(t *testing.T) {
+	payload, err := json.Marshal(publish.EventMessage{Type: "test.event"})
+	require.NoError(t, err)
+	store := &deliveryMockStore{configs: []webhooks.Config{{ID: "config-1", Active: true}}}
+	msg := message.NewMessage("metadata-message", payload)
+	msg.Metadata.Set(deliverAtMetadataKey, "tomorrow")
+	require.ErrorIs(t, processDeliveryMessages(store)(msg), errInvalidDeliverAt)
+
+	body, err := json.Marshal(map[string]any{"type": "test.event", "deliverAt": "2024-13-01"})
+	require.NoError(t, err)
+	require.ErrorIs(t, processDeliveryMessages(store)(message.NewMessage("body-message", body)), errInvalidDeliverAt)
+	require.Empty(t, store.deliveries)
+}
+
+func TestDeliveryRouterDeadLettersRejectedEvents(t *testing.T) {
+	store := &deliveryMockStore{configs: []webhooks.Config{{ID: "config-1", Active: true}}}
+	publisher := &recordingPublisher{}
+	subscriber := newSingleMessageSubscriber()
+	router := message.NewDefaultRouter(watermill.NopLogger{})
+	require.NoError(t, configureMessageRouter(router, subscriber, publisher, []string{"events"}, "dead-letter", store))
+	go func() { _ = router.Run(context.Background()) }()
+	t.Cleanup(func() { _ = router.Close() })
+	<-router.Running()
+
+	payload, err := json.Marshal(publish.EventMessage{Type: "test.event"})
+	require.NoError(t, err)
+	msg := message.NewMessage("malformed-message", payload)
+	msg.Metadata.Set(deliverAtMetadataKey, "tomorrow")
+	subscriber.messages <- msg
+	select {
+	case <-msg.Acked():
+	case <-msg.Nacked():
+		t.Fatal("a rejected event must not be redelivered")
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not acknowledged")
+	}
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	require.Equal(t, []string{"dead-letter"}, publisher.topics)
+	require.Equal(t, "malformed-message", publisher.messages[0].UUID)
+	require.Empty(t, store.deliveries)
+}
+
 func TestDeliveryRouterAcknowledgesOnlyAfterDurableEnqueueReturns(t *testing.T) {
 	started := make(chan struct{})
 	release := make(chan struct{})
@@ -243,8 +385,9 @@ func TestDeliveryDispatcherPersistsRetryableFailure(t *testing.T) {
 			Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
 		}},
 	}
-	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, &noRetryPolicy{}, 1)
-	dispatcher.dispatch(context.Background())
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, &noRetryPolicy{}, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
 
 	require.Len(t, store.completed, 1)
 	require.Equal(t, webhooks.StatusDeliveryPending, store.completed[0].Status)
@@ -256,14 +399,130 @@ func TestDeliveryDispatcherPersistsRetryableFailure(t *testing.T) {
 	require.Equal(t, "temporary", store.attempts[0].ResponseExcerpt)
 }
 
+// maintenanceAround returns a daily window that contains t.
+func maintenanceAround(t time.Time) webhooks.MaintenanceWindow {
+	start := t.Add(-time.Minute)
+	return webhooks.MaintenanceWindow{
+		Schedule: fmt.Sprintf("%d %d * * *", start.Minute(), start.Hour()),
+		Duration: "1h",
+	}
+}
+
+func TestDeliveryDispatcherPostponesClaimsDuringMaintenance(t *testing.T) {
+	now := time.Now().UTC()
+	store := &deliveryMockStore{
+		configs: []webhooks.Config{{ConfigUser: webhooks.ConfigUser{
+			Endpoint: "https://example.invalid", Secret: webhooks.NewSecret(),
+			MaintenanceWindows: []webhooks.MaintenanceWindow{maintenanceAround(now)},
+		}, ID: "config-1", Active: true}},
+		claimed: []webhooks.Delivery{{
+			ID: "delivery-1", ConfigID: "config-1", Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+		}},
+	}
+	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, time.Second, &noRetryPolicy{}, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
+
+	require.Empty(t, store.attempts, "no attempt must be made during a maintenance window")
+	require.Contains(t, store.released, "delivery-1")
+	require.True(t, store.released["delivery-1"].After(now))
+	require.InDelta(t, store.released["delivery-1"].Sub(now), store.postponed["delivery-1"], float64(time.Second),
+		"the retry cycle must be shifted by the postponement")
+}
+
+func TestDeliveryDispatcherMovesRetriesOutOfMaintenance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	now := time.Now().UTC()
+	window := maintenanceAround(now.Add(2 * time.Hour))
+	store := &deliveryMockStore{
+		configs: []webhooks.Config{{ConfigUser: webhooks.ConfigUser{
+			Endpoint: server.URL, Secret: webhooks.NewSecret(),
+			MaintenanceWindows: []webhooks.MaintenanceWindow{window},
+		}, ID: "config-1", Active: true}},
+		claimed: []webhooks.Delivery{{
+			ID: "delivery-1", ConfigID: "config-1", Payload: `{}`,
+			Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+		}},
+	}
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, fixedRetryPolicy(2*time.Hour), 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
+
+	require.Len(t, store.completed, 1)
+	_, end, ok := store.configs[0].NextMaintenanceWindow(now.Add(2 * time.Hour))
+	require.True(t, ok)
+	require.Equal(t, end, *store.completed[0].NextAttemptAt)
+	require.Equal(t, 1, store.completed[0].AttemptCount)
+}
+
+func TestDeliveryDispatcherKeepsMaintenanceOutOfTheRetryWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	now := time.Now().UTC()
+	cycleStartedAt := now.Add(-30 * time.Minute)
+	store := &deliveryMockStore{
+		configs: []webhooks.Config{{ConfigUser: webhooks.ConfigUser{
+			Endpoint: server.URL, Secret: webhooks.NewSecret(),
+			MaintenanceWindows: []webhooks.MaintenanceWindow{maintenanceAround(now.Add(2 * time.Hour))},
+		}, ID: "config-1", Active: true}},
+		claimed: []webhooks.Delivery{{
+			ID: "delivery-1", ConfigID: "config-1", Payload: `{}`, AttemptCount: 1,
+			Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now, CycleStartedAt: &cycleStartedAt,
+		}},
+	}
+	// Retries are allowed for 3h: 30m spent, a 2h delay, then ~1h of
+	// maintenance would exceed it unless the window is left out.
+	policy := windowedRetryPolicy{delay: 2 * time.Hour, window: 3 * time.Hour}
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, policy, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
+
+	require.Len(t, store.completed, 1)
+	completed := store.completed[0]
+	require.Equal(t, webhooks.StatusDeliveryPending, completed.Status)
+	require.NotNil(t, completed.CycleStartedAt)
+	require.True(t, completed.CycleStartedAt.After(cycleStartedAt))
+	require.Error(t, policy.LimitRetryWindow(completed.NextAttemptAt.Sub(cycleStartedAt)))
+	require.NoError(t, policy.LimitRetryWindow(completed.NextAttemptAt.Sub(*completed.CycleStartedAt)))
+}
+
+type fixedRetryPolicy time.Duration
+
+func (p fixedRetryPolicy) GetRetryDelay(int) (time.Duration, error) {
+	return time.Duration(p), nil
+}
+
+// windowedRetryPolicy retries after a fixed delay for as long as the retry
+// cycle is younger than window.
+type windowedRetryPolicy struct {
+	delay  time.Duration
+	window time.Duration
+}
+
+func (p windowedRetryPolicy) GetRetryDelay(int) (time.Duration, error) {
+	return p.delay, nil
+}
+
+func (p windowedRetryPolicy) LimitRetryWindow(elapsed time.Duration) error {
+	if elapsed > p.window {
+		return errors.New("retry window exceeded")
+	}
+	return nil
+}
+
 func TestNewDeliveryDispatcherAppliesDefaultHTTPTimeout(t *testing.T) {
 	client := &http.Client{}
-	dispatcher := NewDeliveryDispatcher(&deliveryMockStore{}, client, time.Second, &noRetryPolicy{}, 1)
+	dispatcher := NewDeliveryDispatcher(&deliveryMockStore{}, client, time.Second, &noRetryPolicy{}, 1, ClaimConfig{})
 	require.Equal(t, defaultDeliveryHTTPTimeout, dispatcher.httpClient.Timeout)
 	require.Zero(t, client.Timeout, "the injected client must not be mutated")
 
 	configured := &http.Client{Timeout: 5 * time.Second}
-	dispatcher = NewDeliveryDispatcher(&deliveryMockStore{}, configured, time.Second, &noRetryPolicy{}, 1)
+	dispatcher = NewDeliveryDispatcher(&deliveryMockStore{}, configured, time.Second, &noRetryPolicy{}, 1, ClaimConfig{})
 	require.Same(t, configured, dispatcher.httpClient)
 	require.Equal(t, 5*time.Second, dispatcher.httpClient.Timeout)
 }
@@ -283,7 +542,7 @@ func TestRunDeliveryDispatcherWaitsForRunToFinishOnStop(t *testing.T) {
 	store := &deliveryMockStore{
 		claimStarted: claimStarted, claimCancelled: claimCancelled, claimRelease: claimRelease,
 	}
-	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, time.Hour, &noRetryPolicy{}, 1)
+	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, time.Hour, &noRetryPolicy{}, 1, ClaimConfig{})
 	lifecycle := &lifecycleRecorder{}
 	runDeliveryDispatcher(lifecycle, dispatcher)
 	require.NoError(t, lifecycle.hook.OnStart(context.Background()))
@@ -311,6 +570,271 @@ func TestRunDeliveryDispatcherWaitsForRunToFinishOnStop(t *testing.T) {
 	require.NoError(t, <-stopped)
 }
 
+func TestDeliveryDispatcherIsNotReadyWhileItsLoopIsStalled(t *testing.T) {
+	claimStarted := make(chan struct{})
+	claimCancelled := make(chan struct{})
+	claimRelease := make(chan struct{})
+	store := &deliveryMockStore{
+		claimStarted: claimStarted, claimCancelled: claimCancelled, claimRelease: claimRelease,
+	}
+	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, 10*time.Millisecond, &noRetryPolicy{}, 1, ClaimConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	select {
+	case <-claimStarted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatcher did not start claiming")
+	}
+	require.Eventually(t, func() bool {
+		return errors.Is(dispatcher.Ready(context.Background()), errDispatcherStalled)
+	}, 2*time.Second, 5*time.Millisecond, "a loop stuck on a claim must fail readiness")
+
+	cancel()
+	<-claimCancelled
+	close(claimRelease)
+	<-done
+}
+
+func TestDeliveryDispatcherWakesUpOnDueNotification(t *testing.T) {
+	store := &deliveryMockStore{claims: make(chan struct{}, 2), dueNotify: make(chan func(), 1)}
+	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, time.Hour, &noRetryPolicy{}, 1, ClaimConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitClaim := func(message string) {
+		select {
+		case <-store.claims:
+		case <-time.After(2 * time.Second):
+			t.Fatal(message)
+		}
+	}
+	waitClaim("dispatcher did not claim on start")
+	var notify func()
+	select {
+	case notify = <-store.dueNotify:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatcher did not listen for due deliveries")
+	}
+	notify()
+	waitClaim("dispatcher did not claim after a due notification, an hour before its next tick")
+}
+
+func TestDeliveryDispatcherKeepsClaimingWhileAnEndpointIsSlow(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("formance-webhook-id") == "slow" {
+			<-release
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	now := time.Now().UTC()
+	store := &deliveryMockStore{configs: []webhooks.Config{{
+		ConfigUser: webhooks.ConfigUser{Endpoint: server.URL, Secret: webhooks.NewSecret()},
+		ID:         "config-1", Active: true,
+	}}}
+	for _, id := range []string{"slow", "fast-1", "fast-2", "fast-3", "fast-4", "fast-5"} {
+		store.claimed = append(store.claimed, webhooks.Delivery{
+			ID: id, ConfigID: "config-1", Payload: `{}`, Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+		})
+	}
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 2, ClaimConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.completed) == 5
+	}, 2*time.Second, 10*time.Millisecond, "fast deliveries must not wait for the slow one")
+}
+
+func TestClaimConfigValidate(t *testing.T) {
+	require.NoError(t, ClaimConfig{}.Validate())
+	require.NoError(t, ClaimConfig{LeaseDuration: time.Minute}.Validate())
+	require.ErrorIs(t, ClaimConfig{LeaseDuration: time.Nanosecond}.Validate(), ErrInvalidClaimLease)
+	require.ErrorIs(t, ClaimConfig{LeaseDuration: defaultDeliveryHTTPTimeout}.Validate(), ErrInvalidClaimLease,
+		"a lease must outlive an attempt")
+}
+
+func TestDeliveryDispatcherHeartbeatsInFlightClaims(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	now := time.Now().UTC()
+	store := &deliveryMockStore{
+		configs: []webhooks.Config{{
+			ConfigUser: webhooks.ConfigUser{Endpoint: server.URL, Secret: webhooks.NewSecret()},
+			ID:         "config-1", Active: true,
+		}},
+		claimed: []webhooks.Delivery{{
+			ID: "slow", ConfigID: "config-1", Payload: `{}`, Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+		}},
+	}
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 1,
+		ClaimConfig{WorkerID: "worker-1", LeaseDuration: 30 * time.Millisecond})
+	require.Equal(t, "worker-1", dispatcher.lease.WorkerID)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.heartbeats) >= 2
+	}, 2*time.Second, 5*time.Millisecond, "the lease of a slow delivery must keep being extended")
+	store.mu.Lock()
+	require.Equal(t, []string{"slow"}, store.heartbeats[0])
+	store.mu.Unlock()
+	info := dispatcher.Info()
+	require.Equal(t, "worker-1", info.ID)
+	require.EqualValues(t, 1, info.InFlight)
+	require.False(t, info.LastHeartbeatAt.IsZero())
+
+	close(release)
+	dispatcher.wait()
+	time.Sleep(30 * time.Millisecond)
+	store.mu.Lock()
+	require.Len(t, store.completed, 1)
+	heartbeats := len(store.heartbeats)
+	store.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	require.Len(t, store.heartbeats, heartbeats, "finished deliveries must not be heartbeated")
+	require.Equal(t, "worker-1", store.attempts[0].WorkerID)
+	require.Greater(t, len(store.registered), 1, "heartbeats must refresh the worker registration")
+	require.Equal(t, "worker-1", store.registered[0].ID)
+	require.Equal(t, []string{"worker-1"}, store.deregistered)
+}
+
+func TestDeliveryDispatcherDrainsInFlightDeliveriesOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+	now := time.Now().UTC()
+	store := &deliveryMockStore{
+		configs: []webhooks.Config{{
+			ConfigUser: webhooks.ConfigUser{Endpoint: server.URL, Secret: webhooks.NewSecret()},
+			ID:         "config-1", Active: true,
+		}},
+		claimed: []webhooks.Delivery{{
+			ID: "in-flight", ConfigID: "config-1", Payload: `{}`, Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+		}},
+	}
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 1,
+		ClaimConfig{DrainTimeout: 5 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	<-started
+	cancel()
+	<-done
+
+	require.Len(t, store.completed, 1, "the in-flight delivery must finish during the drain")
+	require.Equal(t, webhooks.StatusDeliverySucceeded, store.completed[0].Status)
+	require.Empty(t, store.released)
+}
+
+func TestDeliveryDispatcherRequeuesDeliveriesAbortedByTheDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	now := time.Now().UTC()
+	store := &deliveryMockStore{
+		configs: []webhooks.Config{{
+			ConfigUser: webhooks.ConfigUser{Endpoint: server.URL, Secret: webhooks.NewSecret()},
+			ID:         "config-1", Active: true,
+		}},
+		claimed: []webhooks.Delivery{{
+			ID: "stuck", ConfigID: "config-1", Payload: `{}`, Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+		}},
+	}
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 1,
+		ClaimConfig{DrainTimeout: 300 * time.Millisecond})
+	handler := NewWorkerHandler(false, dispatcher, health.Checks{Checks: []health.Check{
+		{Name: "dispatcher", Check: dispatcher.Ready},
+	}})
+	require.ErrorIs(t, dispatcher.Ready(context.Background()), errDispatcherStopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return dispatcher.Info().InFlight == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	require.NoError(t, dispatcher.Ready(context.Background()))
+	cancel()
+
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathHealthCheck, nil))
+		return rec.Code == http.StatusServiceUnavailable && strings.Contains(rec.Body.String(), `"inFlight":1`)
+	}, time.Second, 5*time.Millisecond, "the health check must report the drain")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathReady, nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), errDispatcherDraining.Error())
+	<-done
+
+	require.Empty(t, store.completed, "an aborted send is not an attempt")
+	require.Contains(t, store.released, "stuck")
+	require.WithinDuration(t, time.Now(), store.released["stuck"], time.Second)
+}
+
+func TestDeliveryDispatcherRequeuesUnstartedDeliveriesWhileDraining(t *testing.T) {
+	now := time.Now().UTC()
+	store := &deliveryMockStore{claimed: []webhooks.Delivery{{
+		ID: "unstarted", ConfigID: "config-1", Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+	}}}
+	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, time.Hour, &noRetryPolicy{}, 1, ClaimConfig{})
+	dispatcher.draining.Store(true)
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
+
+	require.Contains(t, store.released, "unstarted")
+	require.Empty(t, store.completed)
+}
+
 func TestDeliveryDispatcherLeavesClaimRecoverableOnConfigLookupError(t *testing.T) {
 	now := time.Now().UTC()
 	store := &deliveryMockStore{
@@ -320,7 +844,9 @@ func TestDeliveryDispatcherLeavesClaimRecoverableOnConfigLookupError(t *testing.
 			ClaimedAt: &now,
 		}},
 	}
-	NewDeliveryDispatcher(store, http.DefaultClient, time.Second, &noRetryPolicy{}, 1).dispatch(context.Background())
+	dispatcher := NewDeliveryDispatcher(store, http.DefaultClient, time.Second, &noRetryPolicy{}, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
 
 	require.Empty(t, store.completed)
 	require.Empty(t, store.cancelled, "transient lookup errors must leave the claim for stale recovery")
@@ -351,7 +877,9 @@ func TestDeliveryDispatcherFailsExpiredDeliveryBeforeCallingEndpoint(t *testing.
 			ClaimedAt: &now, CycleStartedAt: &cycleStartedAt,
 		}},
 	}
-	NewDeliveryDispatcher(store, server.Client(), time.Second, expiredWindowPolicy{}, 1).dispatch(context.Background())
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, expiredWindowPolicy{}, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
 
 	require.Zero(t, hits)
 	require.Equal(t, []string{"delivery-expired"}, store.failedClaims)
@@ -382,7 +910,9 @@ func TestDeliveryDispatcherEnforcesAttemptCapBeforeCallingEndpoint(t *testing.T)
 			ClaimedAt: &now, CycleStartedAt: &now, AttemptCount: 15,
 		}},
 	}
-	NewDeliveryDispatcher(store, server.Client(), time.Second, cappedRetryPolicy{}, 1).dispatch(context.Background())
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, cappedRetryPolicy{}, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
 
 	require.Zero(t, hits)
 	require.Equal(t, []string{"delivery-attempt-capped"}, store.failedClaims)
@@ -404,10 +934,88 @@ func TestDeliveryDispatcherFailsPermanent404WithoutRetry(t *testing.T) {
 			Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
 		}},
 	}
-	NewDeliveryDispatcher(store, server.Client(), time.Second, &noRetryPolicy{}, 1).dispatch(context.Background())
+	dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Second, &noRetryPolicy{}, 1, ClaimConfig{})
+	dispatcher.dispatch(context.Background(), context.Background())
+	dispatcher.wait()
 
 	require.Len(t, store.completed, 1)
 	require.Equal(t, webhooks.StatusDeliveryFailed, store.completed[0].Status)
 	require.Nil(t, store.completed[0].NextAttemptAt)
 	require.Equal(t, webhooks.OutcomeDeliveryPermanentFailure, store.attempts[0].Outcome)
 }
+
+// BenchmarkDeliveryDispatcherSlowEndpoint sends deliveries to an endpoint that
+// answers one request in ten after 50ms, through 10 workers. "batch" claims
+// then waits for the whole batch, as the dispatcher used to; "continuous" is
+// Run.
+func TestMinClaimSizeGivesEveryLaneASlot(t *testing.T) {
+	require.Equal(t, 1, minClaimSize(1))
+	require.Equal(t, 3, minClaimSize(10))
+	require.Equal(t, 12, minClaimSize(50))
+	for batchSize := len(webhooks.Priorities); batchSize <= 100; batchSize++ {
+		for lane, quota := range webhooks.PriorityQuotas(minClaimSize(batchSize)) {
+			require.Positive(t, quota, "lane %s of a batch of %d", webhooks.Priorities[lane], batchSize)
+		}
+	}
+}
+
+func BenchmarkDeliveryDispatcherSlowEndpoint(b *testing.B) {
+	const deliveries = 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.Header.Get("formance-webhook-id"), "0") {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	newStore := func() *deliveryMockStore {
+		now := time.Now().UTC()
+		store := &deliveryMockStore{configs: []webhooks.Config{{
+			ConfigUser: webhooks.ConfigUser{Endpoint: server.URL, Secret: webhooks.NewSecret()},
+			ID:         "config-1", Active: true,
+		}}}
+		for i := 0; i < deliveries; i++ {
+			store.claimed = append(store.claimed, webhooks.Delivery{
+				ID: fmt.Sprintf("delivery-%d", i), ConfigID: "config-1", Payload: `{}`,
+				Status: webhooks.StatusDeliveryDelivering, ClaimedAt: &now,
+			})
+		}
+		return store
+	}
+	completed := func(store *deliveryMockStore) int {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.completed)
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		start := time.Now()
+		for i := 0; i < b.N; i++ {
+			store := newStore()
+			dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 10, ClaimConfig{})
+			for completed(store) < deliveries {
+				dispatcher.dispatch(context.Background(), context.Background())
+				dispatcher.wait()
+			}
+		}
+		b.ReportMetric(float64(b.N*deliveries)/time.Since(start).Seconds(), "deliveries/s")
+	})
+	b.Run("continuous", func(b *testing.B) {
+		start := time.Now()
+		for i := 0; i < b.N; i++ {
+			store := newStore()
+			dispatcher := NewDeliveryDispatcher(store, server.Client(), time.Hour, &noRetryPolicy{}, 10, ClaimConfig{})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				dispatcher.Run(ctx)
+			}()
+			for completed(store) < deliveries {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			<-done
+		}
+		b.ReportMetric(float64(b.N*deliveries)/time.Since(start).Seconds(), "deliveries/s")
+	})
+}
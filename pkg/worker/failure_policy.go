@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/formancehq/go-libs/v2/logging"
+	"github.com/formancehq/go-libs/v2/publish"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultFailureWindow        = time.Hour
+	defaultFailureMinDeliveries = 20
+
+	// defaultNotificationsTopic receives the events webhooks emits about
+	// itself when FailurePolicyConfig.Topic is empty.
+	defaultNotificationsTopic = "webhooks"
+)
+
+var (
+	ErrInvalidFailureAction = errors.New("failure action should be deactivate or pause")
+	ErrInvalidFailureRate   = errors.New("failure rate should be a percentage between 0 and 100")
+)
+
+// FailurePolicyConfig disables the configs whose deliveries keep failing.
+type FailurePolicyConfig struct {
+	// ConsecutiveFailures disables a config once that many of its deliveries
+	// failed in a row. Zero disables the check.
+	ConsecutiveFailures int
+	// FailureRate disables a config once that percentage of its deliveries
+	// finished over Window failed, provided at least MinDeliveries finished.
+	// Zero disables the check.
+	FailureRate   float64
+	Window        time.Duration
+	MinDeliveries int
+	// Action is webhooks.DisableActionPause, the default, or
+	// webhooks.DisableActionDeactivate.
+	Action string
+	// Topic is the broker topic CONFIG_DISABLED events are published on.
+	Topic string
+}
+
+func (c FailurePolicyConfig) Enabled() bool {
+	return c.ConsecutiveFailures > 0 || c.FailureRate > 0
+}
+
+func (c FailurePolicyConfig) Validate() error {
+	if c.Action != "" && !webhooks.IsValidDisableAction(c.Action) {
+		return fmt.Errorf("%w: %q", ErrInvalidFailureAction, c.Action)
+	}
+	if c.FailureRate < 0 || c.FailureRate > 100 {
+		return ErrInvalidFailureRate
+	}
+	return nil
+}
+
+// FailurePolicy checks the outcome of the deliveries of each config and
+// disables the config when it crosses a threshold. The owner is told through
+// the broker and, when the config has one, its owner contact URL.
+type FailurePolicy struct {
+	store      failurePolicyStore
+	publisher  message.Publisher
+	httpClient *http.Client
+	cfg        FailurePolicyConfig
+
+	// notifying counts the owner notifications still being sent.
+	notifying sync.WaitGroup
+}
+
+type failurePolicyStore interface {
+	CountFinishedDeliveries(ctx context.Context, configID string, since time.Time) (failed, total int64, err error)
+	DisableOneConfig(ctx context.Context, id, action, reason string) (webhooks.Config, error)
+}
+
+func NewFailurePolicy(store failurePolicyStore, publisher message.Publisher, httpClient *http.Client, cfg FailurePolicyConfig) *FailurePolicy {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultFailureWindow
+	}
+	if cfg.MinDeliveries <= 0 {
+		cfg.MinDeliveries = defaultFailureMinDeliveries
+	}
+	if cfg.Action == "" {
+		cfg.Action = webhooks.DisableActionPause
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = defaultNotificationsTopic
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultDeliveryHTTPTimeout}
+	}
+	return &FailurePolicy{store: store, publisher: publisher, httpClient: httpClient, cfg: cfg}
+}
+
+// Observe checks cfg after the completion of one of its deliveries. Only
+// failed deliveries can disable a config.
+func (p *FailurePolicy) Observe(ctx context.Context, cfg webhooks.Config, completion webhooks.DeliveryCompletion) {
+	if completion.Status != webhooks.StatusDeliveryFailed {
+		return
+	}
+	reason, err := p.reason(ctx, cfg.ID, completion.ConsecutiveFailures)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("failure policy: checking config %s: %s", cfg.ID, err)
+		return
+	}
+	if reason == "" {
+		return
+	}
+	disabled, err := p.store.DisableOneConfig(ctx, cfg.ID, p.cfg.Action, reason)
+	if err != nil {
+		// Not modified: another worker or an operator got there first.
+		if !errors.Is(err, storage.ErrConfigNotModified) && !errors.Is(err, storage.ErrConfigNotFound) {
+			logging.FromContext(ctx).Errorf("failure policy: disabling config %s: %s", cfg.ID, err)
+		}
+		return
+	}
+	logging.FromContext(ctx).Infof("failure policy: config %s disabled (%s): %s", cfg.ID, p.cfg.Action, reason)
+	p.notify(ctx, disabled)
+}
+
+// reason returns why the config should be disabled, or an empty string when
+// it should not.
+func (p *FailurePolicy) reason(ctx context.Context, configID string, failures int) (string, error) {
+	if p.cfg.ConsecutiveFailures > 0 && failures >= p.cfg.ConsecutiveFailures {
+		return fmt.Sprintf("%d consecutive deliveries failed", failures), nil
+	}
+	if p.cfg.FailureRate <= 0 {
+		return "", nil
+	}
+	failed, total, err := p.store.CountFinishedDeliveries(ctx, configID, time.Now().UTC().Add(-p.cfg.Window))
+	if err != nil || total < int64(p.cfg.MinDeliveries) {
+		return "", err
+	}
+	rate := float64(failed) * 100 / float64(total)
+	if rate < p.cfg.FailureRate {
+		return "", nil
+	}
+	return fmt.Sprintf("%d of the %d deliveries finished in the last %s failed", failed, total, p.cfg.Window), nil
+}
+
+func (p *FailurePolicy) notify(ctx context.Context, cfg webhooks.Config) {
+	event := publish.EventMessage{
+		Date:    cfg.UpdatedAt,
+		App:     "webhooks",
+		Version: "v1",
+		Type:    webhooks.EventTypeConfigDisabled,
+		Payload: webhooks.ConfigDisabled{
+			ConfigID: cfg.ID, Name: cfg.Name, Endpoint: cfg.Endpoint,
+			Action: p.cfg.Action, Reason: cfg.DisabledReason, DisabledAt: cfg.UpdatedAt,
+		},
+	}
+	if p.publisher != nil {
+		if err := p.publisher.Publish(p.cfg.Topic, publish.NewMessage(ctx, event)); err != nil {
+			logging.FromContext(ctx).Errorf("failure policy: publishing disable of config %s: %s", cfg.ID, err)
+		}
+	}
+	if cfg.OwnerContactURL == "" {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("failure policy: encoding disable of config %s: %s", cfg.ID, err)
+		return
+	}
+	// The owner endpoint may be as slow as the failing one: it must not hold
+	// the dispatcher worker.
+	p.notifying.Add(1)
+	go func() {
+		defer p.notifying.Done()
+		if err := webhooks.SendNotification(ctx, p.httpClient, cfg.OwnerContactURL, cfg.Secret, payload); err != nil {
+			logging.FromContext(ctx).Errorf("failure policy: notifying owner of config %s: %s", cfg.ID, err)
+		}
+	}()
+}
+
+// Wait blocks until the owner notifications being sent are done.
+func (p *FailurePolicy) Wait() {
+	p.notifying.Wait()
+}
@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/formancehq/go-libs/v2/publish"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/security"
+	"github.com/formancehq/webhooks/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type failurePolicyTestStore struct {
+	failed, total int64
+	ownerURL      string
+	disabled      []string
+	disableErr    error
+}
+
+func (s *failurePolicyTestStore) CountFinishedDeliveries(context.Context, string, time.Time) (int64, int64, error) {
+	return s.failed, s.total, nil
+}
+
+func (s *failurePolicyTestStore) DisableOneConfig(_ context.Context, id, action, reason string) (webhooks.Config, error) {
+	if s.disableErr != nil {
+		return webhooks.Config{}, s.disableErr
+	}
+	s.disabled = append(s.disabled, action)
+	s.disableErr = storage.ErrConfigNotModified
+	return webhooks.Config{
+		ConfigUser: webhooks.ConfigUser{
+			Endpoint: "https://example.com", Secret: testSecret, OwnerContactURL: s.ownerURL,
+		},
+		ID: id, DisabledReason: reason, UpdatedAt: time.Now().UTC(),
+	}, nil
+}
+
+var testSecret = webhooks.NewSecret()
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	topics   []string
+	messages []*message.Message
+}
+
+func (p *recordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, msg := range messages {
+		p.topics = append(p.topics, topic)
+		p.messages = append(p.messages, msg)
+	}
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestFailurePolicyConfigValidate(t *testing.T) {
+	require.False(t, FailurePolicyConfig{}.Enabled())
+	require.True(t, FailurePolicyConfig{ConsecutiveFailures: 3}.Enabled())
+	require.True(t, FailurePolicyConfig{FailureRate: 50}.Enabled())
+	require.NoError(t, FailurePolicyConfig{Action: webhooks.DisableActionPause}.Validate())
+	require.ErrorIs(t, FailurePolicyConfig{Action: "delete"}.Validate(), ErrInvalidFailureAction)
+	require.ErrorIs(t, FailurePolicyConfig{FailureRate: 150}.Validate(), ErrInvalidFailureRate)
+}
+
+func TestFailurePolicyDisablesAfterConsecutiveFailures(t *testing.T) {
+	notifications := make(chan []byte, 1)
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("formance-webhook-timestamp"), 10, 64)
+		ok, err := security.Verify(r.Header.Get("formance-webhook-signature"), r.Header.Get("formance-webhook-id"),
+			timestamp, testSecret, body)
+		if err != nil || !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		notifications <- body
+	}))
+	t.Cleanup(owner.Close)
+
+	store := &failurePolicyTestStore{ownerURL: owner.URL}
+	publisher := &recordingPublisher{}
+	policy := NewFailurePolicy(store, publisher, owner.Client(), FailurePolicyConfig{
+		ConsecutiveFailures: 3, Topic: "notifications",
+	})
+	cfg := webhooks.Config{ID: "config"}
+	failed := func(failures int) webhooks.DeliveryCompletion {
+		return webhooks.DeliveryCompletion{Status: webhooks.StatusDeliveryFailed, ConsecutiveFailures: failures}
+	}
+
+	policy.Observe(context.Background(), cfg, failed(1))
+	policy.Observe(context.Background(), cfg, failed(2))
+	policy.Observe(context.Background(), cfg, webhooks.DeliveryCompletion{Status: webhooks.StatusDeliveryCancelled, ConsecutiveFailures: 3})
+	require.Empty(t, store.disabled, "only failed deliveries disable a config")
+
+	policy.Observe(context.Background(), cfg, failed(3))
+	require.Equal(t, []string{webhooks.DisableActionPause}, store.disabled, "pause is the default action")
+	require.Equal(t, []string{"notifications"}, publisher.topics)
+	_, event, err := publish.UnmarshalMessage(publisher.messages[0])
+	require.NoError(t, err)
+	require.Equal(t, webhooks.EventTypeConfigDisabled, event.Type)
+
+	policy.Wait()
+	sent := publish.EventMessage{}
+	select {
+	case body := <-notifications:
+		require.NoError(t, json.Unmarshal(body, &sent))
+	default:
+		t.Fatal("the owner was not notified with a valid signature")
+	}
+	require.Equal(t, webhooks.EventTypeConfigDisabled, sent.Type)
+	require.Equal(t, "config", sent.Payload.(map[string]any)["configId"])
+	require.Equal(t, "3 consecutive deliveries failed", sent.Payload.(map[string]any)["reason"])
+
+	// Already disabled: nobody is notified twice.
+	policy.Observe(context.Background(), cfg, failed(4))
+	require.Len(t, publisher.topics, 1)
+}
+
+func TestFailurePolicyDisablesAboveFailureRate(t *testing.T) {
+	store := &failurePolicyTestStore{failed: 9, total: 10}
+	policy := NewFailurePolicy(store, nil, nil, FailurePolicyConfig{
+		FailureRate: 90, MinDeliveries: 20, Action: webhooks.DisableActionDeactivate,
+	})
+	failed := webhooks.DeliveryCompletion{Status: webhooks.StatusDeliveryFailed}
+	policy.Observe(context.Background(), webhooks.Config{ID: "config"}, failed)
+	require.Empty(t, store.disabled, "too few deliveries to judge")
+
+	store.total, store.failed = 20, 17
+	policy.Observe(context.Background(), webhooks.Config{ID: "config"}, failed)
+	require.Empty(t, store.disabled)
+
+	store.failed = 18
+	policy.Observe(context.Background(), webhooks.Config{ID: "config"}, failed)
+	require.Equal(t, []string{webhooks.DisableActionDeactivate}, store.disabled)
+}
@@ -1,27 +1,54 @@
 package worker
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/formancehq/go-libs/v2/api"
 	"github.com/formancehq/go-libs/v2/service"
 
 	"github.com/formancehq/go-libs/v2/logging"
+	"github.com/formancehq/webhooks/pkg/health"
 )
 
 const (
 	PathHealthCheck = "/_healthcheck"
+	PathLive        = "/_live"
+	PathReady       = "/_ready"
+	PathWorker      = "/_worker"
 )
 
-func NewWorkerHandler(debug bool) http.Handler {
+func NewWorkerHandler(debug bool, dispatcher *DeliveryDispatcher, checks health.Checks) http.Handler {
 	h := chi.NewRouter()
 	h.Use(service.OTLPMiddleware("webhooks", debug))
-	h.Get(PathHealthCheck, healthCheckHandle)
+	h.Get(PathHealthCheck, healthCheckHandle(dispatcher))
+	h.Get(PathLive, health.LiveHandle)
+	h.Get(PathReady, health.ReadyHandler(checks))
+	h.Get(PathWorker, workerInfoHandle(dispatcher))
 
 	return h
 }
 
-func healthCheckHandle(_ http.ResponseWriter, r *http.Request) {
-	logging.FromContext(r.Context()).Infof("health check OK")
+// healthCheckHandle fails while the dispatcher drains, with its progress.
+func healthCheckHandle(dispatcher *DeliveryDispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if info := dispatcher.Info(); info.Draining {
+			logging.FromContext(r.Context()).Infof("health check: draining, %d deliveries in flight", info.InFlight)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(info)
+			return
+		}
+		logging.FromContext(r.Context()).Infof("health check OK")
+	}
+}
+
+// workerInfoHandle serves the local view of this worker, without the database
+// round trip of the server's /workers.
+func workerInfoHandle(dispatcher *DeliveryDispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		api.RawOk(w, dispatcher.Info())
+	}
 }
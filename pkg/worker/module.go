@@ -6,32 +6,54 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
 	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
 	"github.com/formancehq/go-libs/v2/logging"
 	"github.com/formancehq/go-libs/v2/otlp/otlpmetrics"
 	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/health"
+	"github.com/formancehq/webhooks/pkg/metrics"
 	"github.com/formancehq/webhooks/pkg/storage"
 	"go.uber.org/fx"
 )
 
 var Tracer = otel.Tracer("listener")
 
-func StartModule(cmd *cobra.Command, retriesCron time.Duration, retryPolicy webhooks.BackoffPolicy, retryBatchSize int, topics []string, retention RetentionConfig) fx.Option {
+var (
+	errRouterNotRunning = errors.New("broker subscriptions are not running")
+	errRouterClosed     = errors.New("broker subscriptions are closed")
+)
+
+func StartModule(cmd *cobra.Command, retriesCron time.Duration, retryPolicy webhooks.BackoffPolicy, retryBatchSize int, claims ClaimConfig, topics []string, retention RetentionConfig, configMetrics ConfigMetricsConfig, failures FailurePolicyConfig, deadLetterTopic string) fx.Option {
 	var options []fx.Option
 
-	options = append(options, fx.Invoke(func(r *message.Router, subscriber message.Subscriber, store storage.Store) {
-		configureMessageRouter(r, subscriber, topics, store)
+	options = append(options, fx.Invoke(func(r *message.Router, subscriber message.Subscriber, publisher message.Publisher, store storage.Store) error {
+		return configureMessageRouter(r, subscriber, publisher, topics, deadLetterTopic, store)
 	}))
 	options = append(options,
-		fx.Provide(func(store storage.Store, httpClient *http.Client) *DeliveryDispatcher {
-			return NewDeliveryDispatcher(store, httpClient, retriesCron, retryPolicy, retryBatchSize)
+		fx.Provide(func(store storage.Store, httpClient *http.Client, publisher message.Publisher) *DeliveryDispatcher {
+			dispatcher := NewDeliveryDispatcher(store, httpClient, retriesCron, retryPolicy, retryBatchSize, claims)
+			if failures.Enabled() {
+				dispatcher.SetFailurePolicy(NewFailurePolicy(store, publisher, dispatcher.httpClient, failures))
+			}
+			return dispatcher
 		}),
 		fx.Invoke(runDeliveryDispatcher),
+		health.ProvideCheck(func(dispatcher *DeliveryDispatcher) health.Check {
+			return health.Check{Name: "dispatcher", Check: dispatcher.Ready}
+		}),
+		health.ProvideCheck(func(r *message.Router) health.Check {
+			return health.Check{Name: "broker", Check: func(context.Context) error {
+				return routerReady(r)
+			}}
+		}),
 	)
 
 	// Only register the DB-backed queue-depth gauge when metrics are actually
@@ -44,6 +66,23 @@ func StartModule(cmd *cobra.Command, retriesCron time.Duration, retryPolicy webh
 		options = append(options, fx.Invoke(func(store storage.Store) error {
 			return registerQueueDepthMetric(store)
 		}))
+		options = append(options, fx.Invoke(func(store storage.Store) error {
+			return registerQueueAgeMetric(store)
+		}))
+		if configMetrics.Enabled() {
+			options = append(options, fx.Invoke(func(store storage.Store) error {
+				return registerConfigBacklogMetric(store)
+			}))
+		}
+	}
+
+	if configMetrics.Enabled() {
+		options = append(options,
+			fx.Provide(func(store storage.Store) *ConfigMetrics {
+				return NewConfigMetrics(store, configMetrics)
+			}),
+			fx.Invoke(runConfigMetrics),
+		)
 	}
 
 	if retention.Enabled() {
@@ -58,20 +97,65 @@ func StartModule(cmd *cobra.Command, retriesCron time.Duration, retryPolicy webh
 	return fx.Options(options...)
 }
 
-// registerQueueDepthMetric registers the retry-queue-depth observable gauge. It
-// binds to the global meter provider (a no-op when metrics are disabled), so the
-// callback only queries the store when a real collector is scraping.
+// registerQueueDepthMetric registers the retry-queue-depth observable gauge, with
+// one observation per priority lane. It binds to the global meter provider (a
+// no-op when metrics are disabled), so the callback only queries the store when
+// a real collector is scraping.
 func registerQueueDepthMetric(store storage.Store) error {
 	meter := otel.GetMeterProvider().Meter("webhooks")
 	_, err := meter.Int64ObservableGauge(
 		"webhooks_retry_queue_depth",
-		metric.WithDescription("Number of webhook deliveries currently queued, capped at 1000000"),
+		metric.WithDescription("Number of webhook deliveries currently queued per priority, capped at 1000000"),
 		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
-			n, err := store.CountPendingDeliveries(ctx)
+			counts, err := store.CountPendingDeliveries(ctx)
 			if err != nil {
 				return err
 			}
-			o.Observe(n)
+			for priority, n := range counts {
+				o.Observe(n, metric.WithAttributes(attribute.String("priority", priority)))
+			}
+			return nil
+		}),
+	)
+	return err
+}
+
+// registerQueueAgeMetric registers the gauge of how long the oldest due
+// delivery has been waiting for a worker. It grows when dispatchers fall behind
+// even while the queue depth stays flat.
+func registerQueueAgeMetric(store storage.Store) error {
+	meter := otel.GetMeterProvider().Meter("webhooks")
+	_, err := meter.Float64ObservableGauge(
+		"webhooks_retry_queue_oldest_age_seconds",
+		metric.WithDescription("Time since the oldest claimable pending webhook delivery became due"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			age, err := store.OldestDueDeliveryAge(ctx)
+			if err != nil {
+				return err
+			}
+			o.Observe(age.Seconds())
+			return nil
+		}),
+	)
+	return err
+}
+
+// registerConfigBacklogMetric registers the per-config backlog gauge, observed
+// for the labelled configs only.
+func registerConfigBacklogMetric(store storage.Store) error {
+	meter := otel.GetMeterProvider().Meter("webhooks")
+	_, err := meter.Int64ObservableGauge(
+		"webhooks_config_backlog",
+		metric.WithDescription("Number of pending webhook deliveries of the labelled configs, capped at 1000000"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			counts, err := store.CountPendingDeliveriesByConfig(ctx, metrics.LabelledConfigs())
+			if err != nil {
+				return err
+			}
+			for configID, n := range counts {
+				o.Observe(n, metric.WithAttributes(attribute.String("config_id", configID)))
+			}
 			return nil
 		}),
 	)
@@ -120,8 +204,50 @@ func runRetention(lc fx.Lifecycle, r *Retention) {
 	})
 }
 
-func configureMessageRouter(r *message.Router, subscriber message.Subscriber, topics []string, store storage.Store) {
+// routerReady fails unless the router consumes the subscribed topics.
+func routerReady(r *message.Router) error {
+	switch {
+	case r.IsClosed():
+		return errRouterClosed
+	case !r.IsRunning():
+		return errRouterNotRunning
+	}
+	return nil
+}
+
+func runConfigMetrics(lc fx.Lifecycle, m *ConfigMetrics) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go m.Run(ctx)
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-m.doneCh:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+// configureMessageRouter consumes the topics. Events the worker rejects, such
+// as those with a malformed deliverAt, are published to deadLetterTopic and
+// acknowledged instead of being redelivered forever.
+func configureMessageRouter(r *message.Router, subscriber message.Subscriber, publisher message.Publisher, topics []string, deadLetterTopic string, store deliveryEnqueuer) error {
+	deadLetter, err := middleware.PoisonQueueWithFilter(publisher, deadLetterTopic, isRejectedEvent)
+	if err != nil {
+		return errors.Wrap(err, "configuring the dead-letter topic")
+	}
 	for _, topic := range topics {
-		r.AddConsumerHandler(fmt.Sprintf("messages-%s", topic), topic, subscriber, processDeliveryMessages(store))
+		handler := r.AddConsumerHandler(fmt.Sprintf("messages-%s", topic), topic, subscriber, processDeliveryMessages(store))
+		handler.AddMiddleware(deadLetter)
 	}
+	return nil
+}
+
+func isRejectedEvent(err error) bool {
+	return errors.Is(err, errInvalidDeliverAt)
 }
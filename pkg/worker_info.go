@@ -0,0 +1,24 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Worker is a running dispatcher, as registered in the workers table. Workers
+// heartbeat while they run and deregister when they stop, so a worker whose
+// heartbeat is old has crashed or is stuck.
+type Worker struct {
+	bun.BaseModel `bun:"table:workers"`
+
+	ID              string    `json:"id" bun:",pk"`
+	Hostname        string    `json:"hostname" bun:"hostname,notnull"`
+	Version         string    `json:"version,omitempty" bun:"version"`
+	StartedAt       time.Time `json:"startedAt" bun:"started_at,notnull"`
+	LastHeartbeatAt time.Time `json:"lastHeartbeatAt" bun:"last_heartbeat_at,notnull"`
+	// InFlight counts the deliveries the worker holds a claim on.
+	InFlight int64 `json:"inFlight" bun:"in_flight,scanonly"`
+	// Draining is only known to the worker itself, while it shuts down.
+	Draining bool `json:"draining,omitempty" bun:"-"`
+}
@@ -0,0 +1,41 @@
+//go:build it
+
+package test_suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/formancehq/go-libs/v2/logging"
+	"github.com/formancehq/webhooks/pkg/testserver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// callAPI sends body as JSON to path on srv, for the endpoints the generated
+// client does not cover, and decodes a successful response into out when it
+// is set. It returns the response status code.
+func callAPI(srv *testserver.Server, method, path string, body any, idempotencyKey string, out any) int {
+	GinkgoHelper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		Expect(err).ToNot(HaveOccurred())
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(logging.TestingContext(), method, srv.ServerURL()+path, reader)
+	Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	resp, err := srv.HTTPClient().Do(req)
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < http.StatusMultipleChoices {
+		Expect(json.NewDecoder(resp.Body).Decode(out)).To(Succeed())
+	}
+	return resp.StatusCode
+}
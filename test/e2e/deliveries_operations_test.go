@@ -0,0 +1,232 @@
+//go:build it
+
+package test_suite
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/formancehq/go-libs/v2/api"
+	"github.com/formancehq/go-libs/v2/testing/platform/pgtesting"
+	webhooks "github.com/formancehq/webhooks/pkg"
+	"github.com/formancehq/webhooks/pkg/testserver"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("Delivery operations", func() {
+	var (
+		db  = pgtesting.UsePostgresDatabase(pgServer)
+		srv = testserver.NewTestServer(func() testserver.Configuration {
+			return testserver.Configuration{
+				Postgres: db.GetValue().ConnectionOptions(),
+				Debug:    debug, Output: GinkgoWriter, NatsURL: natsServer.GetValue().URL,
+				RetryPeriod: 100 * time.Millisecond, MinBackoffDelay: 100 * time.Millisecond,
+				AbortAfter: 3 * time.Second,
+			}
+		})
+		received atomic.Int32
+		endpoint *httptest.Server
+	)
+
+	BeforeEach(func() {
+		received.Store(0)
+		endpoint = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			received.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		DeferCleanup(endpoint.Close)
+	})
+
+	insertConfig := func(endpoint string, eventTypes ...string) string {
+		GinkgoHelper()
+		config := api.BaseResponse[webhooks.Config]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/configs", webhooks.ConfigUser{
+			Endpoint: endpoint, EventTypes: eventTypes,
+		}, "", &config)).To(Equal(http.StatusOK))
+		return config.Data.ID
+	}
+
+	publishEvent := func(request webhooks.PublishEventRequest) webhooks.EnqueuedEvent {
+		GinkgoHelper()
+		enqueued := api.BaseResponse[webhooks.EnqueuedEvent]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/events", request, "", &enqueued)).To(Equal(http.StatusOK))
+		return *enqueued.Data
+	}
+
+	getDelivery := func(g Gomega, id string) webhooks.Delivery {
+		delivery := api.BaseResponse[webhooks.Delivery]{}
+		g.Expect(callAPI(srv.GetValue(), http.MethodGet, "/deliveries/"+id, nil, "", &delivery)).To(Equal(http.StatusOK))
+		return *delivery.Data
+	}
+
+	listDeliveries := func(query string) []webhooks.Delivery {
+		GinkgoHelper()
+		deliveries := api.BaseResponse[webhooks.Delivery]{}
+		Expect(callAPI(srv.GetValue(), http.MethodGet, "/deliveries?"+query, nil, "", &deliveries)).To(Equal(http.StatusOK))
+		return deliveries.Cursor.Data
+	}
+
+	It("enqueues an event published over HTTP once per event ID", func() {
+		configID := insertConfig(endpoint.URL, "operations")
+
+		request := webhooks.PublishEventRequest{
+			ID: "http-event", Type: "operations", Payload: json.RawMessage(`{"amount":100}`),
+		}
+		enqueued := publishEvent(request)
+		Expect(enqueued.EventID).To(Equal("http-event"))
+		Expect(enqueued.Matches).To(HaveLen(1))
+		Expect(enqueued.Matches[0].ConfigID).To(Equal(configID))
+		Expect(enqueued.Matches[0].Duplicate).To(BeFalse())
+
+		again := publishEvent(request)
+		Expect(again.Matches).To(HaveLen(1))
+		Expect(again.Matches[0].DeliveryID).To(Equal(enqueued.Matches[0].DeliveryID))
+		Expect(again.Matches[0].Duplicate).To(BeTrue())
+
+		Eventually(func(g Gomega) {
+			g.Expect(getDelivery(g, enqueued.Matches[0].DeliveryID).Status).To(Equal(webhooks.StatusDeliverySucceeded))
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+		Consistently(received.Load).WithTimeout(500 * time.Millisecond).Should(Equal(int32(1)))
+	})
+
+	It("cancels pending deliveries, alone or in bulk, and records why", func() {
+		configID := insertConfig(endpoint.URL, "operations", "operations.other")
+		deliverAt := time.Now().Add(time.Hour)
+		first := publishEvent(webhooks.PublishEventRequest{Type: "operations", DeliverAt: &deliverAt})
+		second := publishEvent(webhooks.PublishEventRequest{Type: "operations", DeliverAt: &deliverAt})
+		other := publishEvent(webhooks.PublishEventRequest{Type: "operations.other", DeliverAt: &deliverAt})
+
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+first.Matches[0].DeliveryID+"/cancel",
+			nil, "", nil)).To(Equal(http.StatusBadRequest), "an idempotency key is required")
+		key := uuid.NewString()
+		cancelled := api.BaseResponse[webhooks.Delivery]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+first.Matches[0].DeliveryID+"/cancel",
+			map[string]string{"reason": "sent by mistake"}, key, &cancelled)).To(Equal(http.StatusOK))
+		Expect(cancelled.Data.Status).To(Equal(webhooks.StatusDeliveryCancelled))
+		Expect(cancelled.Data.CancellationReason).To(Equal("sent by mistake"))
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+first.Matches[0].DeliveryID+"/cancel",
+			map[string]string{"reason": "sent by mistake"}, key, nil)).To(Equal(http.StatusOK), "a retried cancellation is idempotent")
+
+		bulk := api.BaseResponse[webhooks.CancelDeliveriesResult]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/cancel", webhooks.CancelDeliveriesRequest{
+			CreatedAtFrom: time.Now().Add(-time.Hour), ConfigIDs: []string{configID}, EventTypes: []string{"operations"},
+			Reason: "bad batch",
+		}, uuid.NewString(), &bulk)).To(Equal(http.StatusOK))
+		Expect(bulk.Data.Cancelled).To(Equal(1))
+		Expect(bulk.Data.HasMore).To(BeFalse())
+
+		Expect(getDelivery(Default, second.Matches[0].DeliveryID).Status).To(Equal(webhooks.StatusDeliveryCancelled))
+		Expect(getDelivery(Default, second.Matches[0].DeliveryID).CancellationReason).To(Equal("bad batch"))
+		Expect(getDelivery(Default, other.Matches[0].DeliveryID).Status).To(Equal(webhooks.StatusDeliveryPending))
+	})
+
+	It("delivers a pending delivery rescheduled to now", func() {
+		insertConfig(endpoint.URL, "operations")
+		deliverAt := time.Now().Add(time.Hour)
+		deliveryID := publishEvent(webhooks.PublishEventRequest{Type: "operations", DeliverAt: &deliverAt}).Matches[0].DeliveryID
+
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+deliveryID+"/reschedule",
+			webhooks.RescheduleDeliveryRequest{}, "", nil)).To(Equal(http.StatusBadRequest), "an idempotency key is required")
+		rescheduled := api.BaseResponse[webhooks.Delivery]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+deliveryID+"/reschedule",
+			webhooks.RescheduleDeliveryRequest{}, uuid.NewString(), &rescheduled)).To(Equal(http.StatusOK))
+		Expect(*rescheduled.Data.NextAttemptAt).To(BeTemporally("<", deliverAt))
+
+		Eventually(func(g Gomega) {
+			g.Expect(getDelivery(g, deliveryID).Status).To(Equal(webhooks.StatusDeliverySucceeded))
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+		Expect(received.Load()).To(Equal(int32(1)))
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+deliveryID+"/reschedule",
+			webhooks.RescheduleDeliveryRequest{}, uuid.NewString(), nil)).
+			To(Equal(http.StatusConflict), "only pending deliveries can be rescheduled")
+	})
+
+	It("replays a failed delivery to another config", func() {
+		gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		DeferCleanup(gone.Close)
+		insertConfig(gone.URL, "operations")
+		source := publishEvent(webhooks.PublishEventRequest{Type: "operations"}).Matches[0].DeliveryID
+		Eventually(func(g Gomega) {
+			g.Expect(getDelivery(g, source).Status).To(Equal(webhooks.StatusDeliveryFailed))
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+
+		targetID := insertConfig(endpoint.URL, "operations.target")
+		replayed := api.BaseResponse[webhooks.Delivery]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+source+"/replay",
+			webhooks.ReplayDeliveryRequest{TargetConfigID: targetID}, uuid.NewString(), &replayed)).To(Equal(http.StatusOK))
+		Expect(replayed.Data.ID).ToNot(Equal(source))
+		Expect(replayed.Data.ConfigID).To(Equal(targetID))
+		Expect(replayed.Data.ReplayedFrom).To(Equal(source))
+
+		Eventually(func(g Gomega) {
+			g.Expect(getDelivery(g, replayed.Data.ID).Status).To(Equal(webhooks.StatusDeliverySucceeded))
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+		Expect(received.Load()).To(Equal(int32(1)))
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/"+source+"/replay",
+			webhooks.ReplayDeliveryRequest{TargetConfigID: targetID}, uuid.NewString(), nil)).
+			To(Equal(http.StatusConflict), "the target already has a delivery for the event")
+	})
+
+	It("backfills past events to a config created after them", func() {
+		insertConfig(endpoint.URL, "operations")
+		past := publishEvent(webhooks.PublishEventRequest{ID: "past-event", Type: "operations"})
+		Eventually(func(g Gomega) {
+			g.Expect(getDelivery(g, past.Matches[0].DeliveryID).Status).To(Equal(webhooks.StatusDeliverySucceeded))
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+
+		configID := insertConfig(endpoint.URL, "operations")
+		request := webhooks.BackfillConfigRequest{CreatedAtFrom: time.Now().Add(-time.Hour)}
+		backfill := api.BaseResponse[webhooks.BackfillConfigResult]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/configs/"+configID+"/backfill", request,
+			uuid.NewString(), &backfill)).To(Equal(http.StatusOK))
+		Expect(backfill.Data.Enqueued).To(Equal(1))
+		Expect(backfill.Data.HasMore).To(BeFalse())
+
+		Eventually(func(g Gomega) {
+			deliveries := listDeliveries("configId=" + configID + "&eventId=past-event&status=succeeded")
+			g.Expect(deliveries).To(HaveLen(1))
+			g.Expect(deliveries[0].ReplayedFrom).To(BeEmpty())
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+		Expect(received.Load()).To(Equal(int32(2)))
+
+		again := api.BaseResponse[webhooks.BackfillConfigResult]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/configs/"+configID+"/backfill", request,
+			uuid.NewString(), &again)).To(Equal(http.StatusOK))
+		Expect(again.Data.Enqueued).To(BeZero())
+	})
+
+	It("holds scheduled deliveries until their deliverAt and lists them", func() {
+		configID := insertConfig(endpoint.URL, "operations")
+		deliverAt := time.Now().Add(2 * time.Second)
+		soon := publishEvent(webhooks.PublishEventRequest{Type: "operations", DeliverAt: &deliverAt}).Matches[0].DeliveryID
+		later := time.Now().Add(time.Hour)
+		held := publishEvent(webhooks.PublishEventRequest{Type: "operations", DeliverAt: &later}).Matches[0].DeliveryID
+		publishEvent(webhooks.PublishEventRequest{Type: "operations"})
+
+		Expect(callAPI(srv.GetValue(), http.MethodGet, "/deliveries?scheduled=maybe", nil, "", nil)).
+			To(Equal(http.StatusBadRequest))
+		scheduled := listDeliveries("configId=" + configID + "&scheduled=true")
+		Expect(scheduled).To(HaveLen(2), "deliveries due now are not scheduled")
+
+		Eventually(func(g Gomega) {
+			g.Expect(getDelivery(g, soon).Status).To(Equal(webhooks.StatusDeliverySucceeded))
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+		Expect(*getDelivery(Default, soon).LastAttemptAt).To(BeTemporally(">=", deliverAt.Truncate(time.Second)))
+
+		bulk := api.BaseResponse[webhooks.CancelDeliveriesResult]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/deliveries/cancel", webhooks.CancelDeliveriesRequest{
+			CreatedAtFrom: time.Now().Add(-time.Hour), ConfigIDs: []string{configID}, ScheduledOnly: true,
+		}, uuid.NewString(), &bulk)).To(Equal(http.StatusOK))
+		Expect(bulk.Data.Cancelled).To(Equal(1))
+		Expect(getDelivery(Default, held).Status).To(Equal(webhooks.StatusDeliveryCancelled))
+		Expect(listDeliveries("configId=" + configID + "&scheduled=true")).To(BeEmpty())
+		Expect(received.Load()).To(Equal(int32(2)))
+	})
+})
@@ -8,8 +8,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/formancehq/go-libs/v2/api"
 	"github.com/formancehq/go-libs/v2/logging"
 	"github.com/formancehq/go-libs/v2/testing/platform/pgtesting"
+	webhooks "github.com/formancehq/webhooks/pkg"
 	"github.com/formancehq/webhooks/pkg/client/models/components"
 	"github.com/formancehq/webhooks/pkg/client/models/operations"
 	"github.com/formancehq/webhooks/pkg/testserver"
@@ -83,4 +85,39 @@ var _ = Context("Durable deliveries", func() {
 		}).WithTimeout(5 * time.Second).Should(Succeed())
 		Expect(calls.Load()).To(Equal(int32(2)))
 	})
+
+	It("persists the capture of an attempt and returns it with the attempts", func() {
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("X-Receiver", "captured")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		DeferCleanup(endpoint.Close)
+
+		config := api.BaseResponse[webhooks.Config]{}
+		Expect(callAPI(srv.GetValue(), http.MethodPost, "/configs", webhooks.ConfigUser{
+			Endpoint: endpoint.URL, EventTypes: []string{"durable"}, CaptureAttempts: true,
+		}, "", &config)).To(Equal(http.StatusOK))
+		Expect(natsServer.GetValue().Client(GinkgoT()).Publish("durable", []byte(`{"type":"durable","idempotency_key":"captured-event"}`))).To(Succeed())
+
+		var delivery components.Delivery
+		Eventually(func(g Gomega) {
+			response, err := srv.GetValue().Client().Webhooks.V1.GetDeliveries(ctx, operations.GetDeliveriesRequest{
+				ConfigID: &config.Data.ID, Status: components.DeliveryStatusSucceeded.ToPointer(),
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(response.DeliveriesResponse.Cursor.Data).To(HaveLen(1))
+			delivery = response.DeliveriesResponse.Cursor.Data[0]
+		}).WithTimeout(5 * time.Second).Should(Succeed())
+
+		attempts := api.BaseResponse[webhooks.DeliveryAttempt]{}
+		Expect(callAPI(srv.GetValue(), http.MethodGet, "/deliveries/"+delivery.ID+"/attempts", nil, "", &attempts)).
+			To(Equal(http.StatusOK))
+		Expect(attempts.Cursor.Data).To(HaveLen(1))
+		capture := attempts.Cursor.Data[0].Capture
+		Expect(capture).ToNot(BeNil())
+		Expect(capture.RequestHeaders.Get("formance-webhook-id")).To(Equal(delivery.ID))
+		Expect(capture.RequestHeaders.Get("Content-Length")).ToNot(BeEmpty(), "headers are captured as sent")
+		Expect(capture.BodySHA256).ToNot(BeEmpty())
+		Expect(capture.ResponseHeaders.Get("X-Receiver")).To(Equal("captured"))
+	})
 })